@@ -23,6 +23,51 @@ import (
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
+// DeletionPolicy decides what happens to the github issue when the GitHubIssue is deleted
+// +kubebuilder:validation:Enum=Close;Orphan;CloseWithComment
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyClose closes the issue
+	DeletionPolicyClose DeletionPolicy = "Close"
+	// DeletionPolicyOrphan leaves the issue as it is
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+	// DeletionPolicyCloseWithComment posts the deletion comment on the issue and then closes it
+	DeletionPolicyCloseWithComment DeletionPolicy = "CloseWithComment"
+)
+
+// IssueState is the state of a github issue
+// +kubebuilder:validation:Enum=open;closed
+type IssueState string
+
+const (
+	IssueStateOpen   IssueState = "open"
+	IssueStateClosed IssueState = "closed"
+)
+
+// StateReason is the reason github shows for closing an issue
+// +kubebuilder:validation:Enum=completed;not_planned
+type StateReason string
+
+const (
+	StateReasonCompleted  StateReason = "completed"
+	StateReasonNotPlanned StateReason = "not_planned"
+)
+
+// AdoptionPolicy decides whether an existing github issue with the title of the spec is managed by the GitHubIssue
+// instead of opening a new one
+// +kubebuilder:validation:Enum=Never;IfUnowned;Always
+type AdoptionPolicy string
+
+const (
+	// AdoptionPolicyNever always opens a new issue
+	AdoptionPolicyNever AdoptionPolicy = "Never"
+	// AdoptionPolicyIfUnowned adopts the issue unless another GitHubIssue that still exists owns it
+	AdoptionPolicyIfUnowned AdoptionPolicy = "IfUnowned"
+	// AdoptionPolicyAlways adopts the issue, taking it over from the GitHubIssue owning it
+	AdoptionPolicyAlways AdoptionPolicy = "Always"
+)
+
 // GitHubIssueSpec defines the desired state of GitHubIssue
 type GitHubIssueSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
@@ -31,18 +76,182 @@ type GitHubIssueSpec struct {
 	Repo        string `json:"repo"`
 	Title       string `json:"title"`
 	Description string `json:"description"`
+	// Labels of the issue, when empty the issue's labels aren't managed
+	// +optional
+	Labels []string `json:"labels,omitempty"`
+	// Assignees (github logins) of the issue, when empty the issue's assignees aren't managed
+	// +optional
+	Assignees []string `json:"assignees,omitempty"`
+	// Milestone number of the issue, when not set the issue's milestone isn't managed
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Milestone *int `json:"milestone,omitempty"`
+	// AdoptionPolicy of an existing issue with the same title, defaults to IfUnowned. the operator stamps the issues
+	// it manages with a hidden ownership marker in the body
+	// +optional
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+	// DeletionPolicy of the github issue when this object is deleted, defaults to Close
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// DeletionComment is posted on the issue by the CloseWithComment deletion policy
+	// +optional
+	DeletionComment string `json:"deletionComment,omitempty"`
+	// State the issue should be in on github, defaults to open. an issue closed by hand is reopened
+	// +optional
+	State IssueState `json:"state,omitempty"`
+	// StateReason is sent to github when the operator closes the issue
+	// +optional
+	StateReason StateReason `json:"stateReason,omitempty"`
+	// ResyncInterval is how often the issue is compared against github to revert changes made there, defaults
+	// to the operator's --resync-interval. 0s turns the periodic resync off
+	// +optional
+	ResyncInterval *metav1.Duration `json:"resyncInterval,omitempty"`
+	// Comments the operator posts and keeps up to date on the issue. comments written by people are never
+	// touched, and a managed comment removed from the list is deleted from the issue
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Comments []IssueComment `json:"comments,omitempty"`
+	// BaseURL of the github api to use for this issue, e.g. https://github.example.com/api/v3/ for github
+	// enterprise server. defaults to the operator's --github-api-url. requires tokenSecretRef or githubAppRef,
+	// the operator's GITHUB_TOKEN is never sent to another host
+	// +optional
+	BaseURL string `json:"baseURL,omitempty"`
+	// TokenSecretRef points to the secret holding the github token used for this issue, key defaults to "token".
+	// when not set the operator's GITHUB_TOKEN environment variable is used
+	// +optional
+	TokenSecretRef *SecretKeyReference `json:"tokenSecretRef,omitempty"`
+	// GithubAppRef authenticates as a github app installation instead of with a token.
+	// it can't be set together with tokenSecretRef
+	// +optional
+	GithubAppRef *GithubAppReference `json:"githubAppRef,omitempty"`
 }
 
+// IssueComment is a comment the operator manages on the issue
+type IssueComment struct {
+	// Name identifies the comment among the object's comments, it's kept in a hidden marker in the comment body
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_.-]+$`
+	Name string `json:"name"`
+	// Body of the comment
+	Body string `json:"body"`
+}
+
+// CommentStatus is a managed comment as posted on github
+type CommentStatus struct {
+	// Name of the comment in the spec
+	Name string `json:"name"`
+	// ID of the comment on github
+	ID int64 `json:"id"`
+}
+
+// IssueLocation is where a github issue is
+type IssueLocation struct {
+	// Repo of the issue
+	Repo string `json:"repo"`
+	// IssueNumber of the issue in the repo
+	IssueNumber int `json:"issueNumber"`
+	// URL of the issue
+	// +optional
+	URL string `json:"url,omitempty"`
+}
+
+// GithubAppReference holds the github app credentials of a GitHubIssue
+type GithubAppReference struct {
+	// AppID of the github app
+	AppID int64 `json:"appID"`
+	// InstallationID of the app, when not set the installation of the repository is looked up
+	// +optional
+	InstallationID int64 `json:"installationID,omitempty"`
+	// PrivateKeySecretRef points to the secret holding the app's PEM private key, key defaults to "private-key.pem"
+	PrivateKeySecretRef SecretKeyReference `json:"privateKeySecretRef"`
+}
+
+// SecretKeyReference selects a key of a secret in the GitHubIssue's namespace
+type SecretKeyReference struct {
+	// Name of the secret
+	Name string `json:"name"`
+	// Key of the secret data holding the value, the field using the reference documents its default
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// condition types of GitHubIssueStatus
+const (
+	// ConditionReady is true when the github issue matches the spec
+	ConditionReady = "Ready"
+	// ConditionSynced is true when the last reconcile with github succeeded
+	ConditionSynced = "Synced"
+	// ConditionCredentialsValid is false when the github credentials can't be read or github rejects them
+	ConditionCredentialsValid = "CredentialsValid"
+	// ConditionRateLimited is true while github rate limits the credentials
+	ConditionRateLimited = "RateLimited"
+)
+
 // GitHubIssueStatus defines the observed state of GitHubIssue
 type GitHubIssueStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
-	State               string `json:"state,omitempty"`
+	State string `json:"state,omitempty"`
+	// LastUpdateTimestamp is the updated_at of the issue as github sent it, see UpdatedAt
 	LastUpdateTimestamp string `json:"last_update_timestamp,omitempty"`
+	// HTMLURL is the address of the issue on github
+	// +optional
+	HTMLURL string `json:"htmlURL,omitempty"`
+	// Author is the login of the user who opened the issue
+	// +optional
+	Author string `json:"author,omitempty"`
+	// CreatedAt is when the issue was opened
+	// +optional
+	CreatedAt *metav1.Time `json:"createdAt,omitempty"`
+	// UpdatedAt is when the issue was last changed on github
+	// +optional
+	UpdatedAt *metav1.Time `json:"updatedAt,omitempty"`
+	// ClosedAt is when the issue was last closed, unset while it's open
+	// +optional
+	ClosedAt *metav1.Time `json:"closedAt,omitempty"`
+	// CommentCount is the number of comments on the issue, of people and of the operator
+	// +optional
+	CommentCount int `json:"commentCount,omitempty"`
+	// IssueNumber is the number of the github issue this object manages, once it was created or adopted.
+	// reconciles after that fetch the issue by number instead of searching it by title
+	IssueNumber int `json:"issueNumber,omitempty"`
+	// NodeID is the global (graphql) id of the github issue
+	NodeID string `json:"nodeID,omitempty"`
+	// Repo the issue is in. when spec.repo changes the issue is transferred (within an owner) or recreated
+	// (across owners) in the new repo
+	// +optional
+	Repo string `json:"repo,omitempty"`
+	// PreviousLocation of the issue before its last move to another repo
+	// +optional
+	PreviousLocation *IssueLocation `json:"previousLocation,omitempty"`
+	// Labels applied to the issue on github
+	Labels []string `json:"labels,omitempty"`
+	// Assignees of the issue on github
+	Assignees []string `json:"assignees,omitempty"`
+	// Comments the operator manages on the issue
+	// +optional
+	Comments []CommentStatus `json:"comments,omitempty"`
+	// ObservedGeneration is the generation of the spec the status was last reconciled for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// SyncedGeneration is the generation of the spec the issue on github last matched, unlike ObservedGeneration
+	// it isn't moved by a failed reconcile
+	// +optional
+	SyncedGeneration int64 `json:"syncedGeneration,omitempty"`
+	// Conditions of the issue: Ready, Synced, CredentialsValid and RateLimited
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Repo",type=string,JSONPath=`.spec.repo`
+//+kubebuilder:printcolumn:name="Number",type=integer,JSONPath=`.status.issueNumber`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="URL",type=string,JSONPath=`.status.htmlURL`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // GitHubIssue is the Schema for the githubissues API
 type GitHubIssue struct {
@@ -0,0 +1,180 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// log is for logging in this package.
+var githubissuelog = logf.Log.WithName("githubissue-resource")
+
+// github's limits on issues
+const (
+	// MaxTitleLength is the longest title github accepts
+	MaxTitleLength = 256
+	// MaxBodyLength is the longest issue or comment body github accepts
+	MaxBodyLength = 65536
+	// BodyMarkerReserve is left out of MaxBodyLength for the hidden markers the operator adds to bodies
+	BodyMarkerReserve = 256
+)
+
+// ValidatePath is the path the validating webhook of GitHubIssue is served on
+const ValidatePath = "/validate-example-training-redhat-com-v1alpha1-githubissue"
+
+// SetupWebhookWithManager registers the defaulting and validating webhooks of GitHubIssue. the validating webhook
+// lists the namespace to warn about duplicates, so it's served by GitHubIssueValidator instead of a Validator
+func (r *GitHubIssue) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	mgr.GetWebhookServer().Register(ValidatePath, &webhook.Admission{Handler: &GitHubIssueValidator{Client: mgr.GetClient()}})
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-example-training-redhat-com-v1alpha1-githubissue,mutating=true,failurePolicy=fail,sideEffects=None,groups=example.training.redhat.com,resources=githubissues,verbs=create;update,versions=v1alpha1,name=mgithubissue.kb.io,admissionReviewVersions={v1,v1beta1}
+
+var _ webhook.Defaulter = &GitHubIssue{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *GitHubIssue) Default() {
+	githubissuelog.Info("default", "name", r.Name)
+
+	if r.Spec.DeletionPolicy == "" {
+		r.Spec.DeletionPolicy = DeletionPolicyClose
+	}
+	if r.Spec.AdoptionPolicy == "" {
+		r.Spec.AdoptionPolicy = AdoptionPolicyIfUnowned
+	}
+	if r.Spec.State == "" {
+		r.Spec.State = IssueStateOpen
+	}
+	if r.Spec.State == IssueStateClosed && r.Spec.StateReason == "" {
+		r.Spec.StateReason = StateReasonCompleted
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-example-training-redhat-com-v1alpha1-githubissue,mutating=false,failurePolicy=fail,sideEffects=None,groups=example.training.redhat.com,resources=githubissues,verbs=create;update,versions=v1alpha1,name=vgithubissue.kb.io,admissionReviewVersions={v1,v1beta1}
+
+// GitHubIssueValidator rejects GitHubIssue objects github would reject, and warns about objects sharing a title
+// and repo within a namespace (which would manage the same issue)
+type GitHubIssueValidator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+// InjectDecoder implements admission.DecoderInjector
+func (v *GitHubIssueValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle validates a created or updated GitHubIssue
+func (v *GitHubIssueValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	ghIssue := &GitHubIssue{}
+	if err := v.decoder.Decode(req, ghIssue); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	githubissuelog.Info("validate", "name", ghIssue.Name, "operation", req.Operation)
+
+	var oldGhIssue *GitHubIssue
+	if len(req.OldObject.Raw) > 0 {
+		oldGhIssue = &GitHubIssue{}
+		if err := v.decoder.DecodeRaw(req.OldObject, oldGhIssue); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+	if err := ghIssue.validate(oldGhIssue); err != nil {
+		return admission.Denied(err.Error())
+	}
+
+	warnings, err := v.duplicateWarnings(ctx, ghIssue)
+	if err != nil {
+		// duplicates are only a warning, don't block the request on them
+		githubissuelog.Error(err, "unable to look for duplicates", "name", ghIssue.Name)
+	}
+	return admission.Allowed("").WithWarnings(warnings...)
+}
+
+// duplicateWarnings warns about the other objects of the namespace with the same title and repo
+func (v *GitHubIssueValidator) duplicateWarnings(ctx context.Context, ghIssue *GitHubIssue) ([]string, error) {
+	ghIssues := GitHubIssueList{}
+	if err := v.Client.List(ctx, &ghIssues, client.InNamespace(ghIssue.Namespace)); err != nil {
+		return nil, err
+	}
+	var warnings []string
+	for _, other := range ghIssues.Items {
+		if other.Name != ghIssue.Name && other.Spec.Title == ghIssue.Spec.Title &&
+			strings.EqualFold(other.Spec.Repo, ghIssue.Spec.Repo) {
+			warnings = append(warnings, fmt.Sprintf("GitHubIssue %s already has the title %q in %s", other.Name,
+				ghIssue.Spec.Title, ghIssue.Spec.Repo))
+		}
+	}
+	return warnings, nil
+}
+
+// validate checks the object against github's limits, that an object with its own github host has its own
+// credentials, and for an update (old isn't nil) that the github host of an
+// existing issue isn't changed
+func (r *GitHubIssue) validate(old *GitHubIssue) error {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+	if strings.TrimSpace(r.Spec.Title) == "" {
+		allErrs = append(allErrs, field.Required(specPath.Child("title"), "github issues must have a title"))
+	} else if utf8.RuneCountInString(r.Spec.Title) > MaxTitleLength {
+		allErrs = append(allErrs, field.TooLong(specPath.Child("title"), r.Spec.Title, MaxTitleLength))
+	}
+	if utf8.RuneCountInString(r.Spec.Description) > MaxBodyLength-BodyMarkerReserve {
+		allErrs = append(allErrs, field.TooLong(specPath.Child("description"), "", MaxBodyLength-BodyMarkerReserve))
+	}
+	for i, comment := range r.Spec.Comments {
+		if utf8.RuneCountInString(comment.Body) > MaxBodyLength-BodyMarkerReserve {
+			allErrs = append(allErrs, field.TooLong(specPath.Child("comments").Index(i).Child("body"), "",
+				MaxBodyLength-BodyMarkerReserve))
+		}
+	}
+	if r.Spec.TokenSecretRef != nil && r.Spec.GithubAppRef != nil {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("githubAppRef"), "can't be set together with tokenSecretRef"))
+	}
+	// the operator's own token is only ever sent to the operator's github, an object pointing elsewhere brings
+	// its credentials
+	if r.Spec.BaseURL != "" && r.Spec.TokenSecretRef == nil && r.Spec.GithubAppRef == nil {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("baseURL"),
+			"requires tokenSecretRef or githubAppRef, the operator's token isn't sent to another github"))
+	}
+	// spec.repo may change, the operator moves the issue to the new repo. github can't move it to another host
+	if old != nil && old.Status.IssueNumber != 0 && old.Spec.BaseURL != r.Spec.BaseURL {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("baseURL"),
+			fmt.Sprintf("can't be changed once issue #%d exists in %s", old.Status.IssueNumber, old.Spec.Repo)))
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "GitHubIssue"}, r.Name, allErrs)
+}
@@ -0,0 +1,140 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func newTestGitHubIssue(name, title string) *GitHubIssue {
+	return &GitHubIssue{
+		TypeMeta:   metav1.TypeMeta{APIVersion: GroupVersion.String(), Kind: "GitHubIssue"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       GitHubIssueSpec{Repo: "testUser/testRepo", Title: title, Description: "testing..."},
+	}
+}
+
+func newTestValidator(t *testing.T, objects ...runtime.Object) *GitHubIssueValidator {
+	scheme := runtime.NewScheme()
+	if err := AddToScheme(scheme); err != nil {
+		t.Fatalf("adding to scheme: %v", err)
+	}
+	decoder, _ := admission.NewDecoder(scheme)
+	validator := &GitHubIssueValidator{Client: fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()}
+	_ = validator.InjectDecoder(decoder)
+	return validator
+}
+
+func admissionRequest(t *testing.T, ghIssue, old *GitHubIssue) admission.Request {
+	raw, err := json.Marshal(ghIssue)
+	if err != nil {
+		t.Fatalf("encoding the object: %v", err)
+	}
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: admissionv1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}}
+	if old != nil {
+		req.Operation = admissionv1.Update
+		if req.OldObject.Raw, err = json.Marshal(old); err != nil {
+			t.Fatalf("encoding the old object: %v", err)
+		}
+	}
+	return req
+}
+
+func TestDefault(t *testing.T) {
+	//given an object with a closed state and no policies
+	ghIssue := newTestGitHubIssue("ghTest", "testIssue")
+	ghIssue.Spec.State = IssueStateClosed
+
+	//when defaulting
+	ghIssue.Default()
+
+	//then the policies and the state reason are set
+	if ghIssue.Spec.DeletionPolicy != DeletionPolicyClose || ghIssue.Spec.AdoptionPolicy != AdoptionPolicyIfUnowned ||
+		ghIssue.Spec.StateReason != StateReasonCompleted {
+		t.Errorf("Expected the defaults to be set but got: %+v", ghIssue.Spec)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tracked := newTestGitHubIssue("ghTest", "testIssue")
+	tracked.Status.IssueNumber = 3
+	moved := tracked.DeepCopy()
+	moved.Spec.Repo = "testUser/otherRepo"
+	otherHost := tracked.DeepCopy()
+	otherHost.Spec.BaseURL = "https://github.example.com/api/v3/"
+	otherHost.Spec.TokenSecretRef = &SecretKeyReference{Name: "ghes-token"}
+	otherHostOperatorToken := newTestGitHubIssue("ghTest", "testIssue")
+	otherHostOperatorToken.Spec.BaseURL = "https://attacker.example.com/"
+	longBody := newTestGitHubIssue("ghTest", "testIssue")
+	longBody.Spec.Description = strings.Repeat("a", MaxBodyLength)
+	longComment := newTestGitHubIssue("ghTest", "testIssue")
+	longComment.Spec.Comments = []IssueComment{{Name: "ci", Body: strings.Repeat("a", MaxBodyLength)}}
+
+	tests := []struct {
+		name      string
+		ghIssue   *GitHubIssue
+		old       *GitHubIssue
+		expectErr string
+	}{
+		{"valid", newTestGitHubIssue("ghTest", "testIssue"), nil, ""},
+		{"empty title", newTestGitHubIssue("ghTest", "  "), nil, "spec.title: Required value"},
+		{"long title", newTestGitHubIssue("ghTest", strings.Repeat("é", MaxTitleLength+1)), nil, "spec.title: Too long"},
+		{"title at the limit", newTestGitHubIssue("ghTest", strings.Repeat("é", MaxTitleLength)), nil, ""},
+		{"long description", longBody, nil, "spec.description: Too long"},
+		{"long comment", longComment, nil, "spec.comments[0].body: Too long"},
+		{"repo changed after the issue exists", moved, tracked, ""},
+		{"host changed after the issue exists", otherHost, tracked, "spec.baseURL: Forbidden"},
+		{"host changed before the issue exists", otherHost, newTestGitHubIssue("ghTest", "testIssue"), ""},
+		{"host without credentials of its own", otherHostOperatorToken, nil, "spec.baseURL: Forbidden"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.ghIssue.validate(test.old)
+			if test.expectErr == "" && err != nil {
+				t.Errorf("Expected no error but got an error: %v", err)
+			}
+			if test.expectErr != "" && (err == nil || !strings.Contains(err.Error(), test.expectErr)) {
+				t.Errorf("Expected an error with %q but got: %v", test.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestValidatorWarnsAboutDuplicates(t *testing.T) {
+	//given an object with the same title and repo in the namespace
+	validator := newTestValidator(t, newTestGitHubIssue("ghOther", "testIssue"))
+
+	//when creating an object
+	resp := validator.Handle(context.Background(), admissionRequest(t, newTestGitHubIssue("ghTest", "testIssue"), nil))
+
+	//then it's allowed with a warning naming the other object
+	if !resp.Allowed {
+		t.Fatalf("Expected the object to be allowed but got: %v", resp.Result)
+	}
+	if len(resp.Warnings) != 1 || !strings.Contains(resp.Warnings[0], "ghOther") {
+		t.Errorf("Expected a warning about ghOther but got: %v", resp.Warnings)
+	}
+}
+
+func TestValidatorDeniesInvalidObjects(t *testing.T) {
+	//given a validator
+	validator := newTestValidator(t)
+
+	//when creating an object without a title
+	resp := validator.Handle(context.Background(), admissionRequest(t, newTestGitHubIssue("ghTest", ""), nil))
+
+	//then it's denied
+	if resp.Allowed {
+		t.Errorf("Expected the object to be denied")
+	}
+}
@@ -1,24 +1,130 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
+	"time"
+
 	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
 )
 
-const TitleNotFound = "object title not found on github" //error for findIssue function
+const TitleNotFound = "object title not found on github" //message of ErrTitleNotFound
 
 type Client interface {
-	FindIssue(ghIssueSpec examplev1alpha1.GitHubIssueSpec, token string) (*Issue, error)
-	Create(ghIssueSpec examplev1alpha1.GitHubIssueSpec, token string) (*Issue, error)
-	Edit(ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, token string) error
-	Close(ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, token string) error
+	FindIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, tokenSource TokenSource) (*Issue, error)
+	// FindIssueByMarker returns the latest created issue with the marker in its body, among the issues updated
+	// since the given time. unlike searching, listing the issues sees the ones created a moment ago
+	FindIssueByMarker(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, marker string, since time.Time, tokenSource TokenSource) (*Issue, error)
+	GetIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string, tokenSource TokenSource) (*Issue, error)
+	Create(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, tokenSource TokenSource) (*Issue, error)
+	Edit(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string, tokenSource TokenSource) (*Issue, error)
+	Close(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string, tokenSource TokenSource) error
+	TransferIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, targetRepo string, tokenSource TokenSource) (*Issue, error)
+	// LocateIssue returns the repo ("owner/name") and the number of the issue with the node id, wherever it was
+	// transferred to since
+	LocateIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, nodeID string, tokenSource TokenSource) (string, string, error)
+	ListComments(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string, tokenSource TokenSource) ([]*Comment, error)
+	CreateComment(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, body string, tokenSource TokenSource) (*Comment, error)
+	EditComment(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, comment *Comment, body string, tokenSource TokenSource) (*Comment, error)
+	DeleteComment(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, comment *Comment, tokenSource TokenSource) error
 }
 
 type Issue struct {
 	Repo                string      `json:"url"`
+	HTMLURL             string      `json:"html_url,omitempty"`
 	Title               string      `json:"title"`
 	Description         string      `json:"body"`
 	IssueNumber         json.Number `json:"number,omitempty"` //TODO change here and everywhere to int and check it's working
 	State               string      `json:"state,omitempty"`
+	StateReason         string      `json:"state_reason,omitempty"`
 	LastUpdateTimestamp string      `json:"updated_at"`
+	NodeID              string      `json:"node_id,omitempty"`
+	Labels              []Label     `json:"labels,omitempty"`
+	Assignees           []User      `json:"assignees,omitempty"`
+	Milestone           *Milestone  `json:"milestone,omitempty"`
+	Author              *User       `json:"user,omitempty"`
+	Comments            int         `json:"comments,omitempty"`
+	CreatedAt           *time.Time  `json:"created_at,omitempty"`
+	ClosedAt            *time.Time  `json:"closed_at,omitempty"`
+	// PullRequest is only set for the pull requests github lists among the issues of a repository
+	PullRequest *json.RawMessage `json:"pull_request,omitempty"`
+	// FetchedComments are the comments fetched along with the issue, nil when the client fetches them apart
+	// (see ListComments). the graphql client fetches up to the first 100
+	FetchedComments []*Comment `json:"-"`
+	// ProjectItems are the items of the issue in projects (v2), only the graphql client fetches them, and only
+	// with a token that has the read:project scope
+	ProjectItems []ProjectItem `json:"-"`
+}
+
+type Comment struct {
+	ID     int64  `json:"id,omitempty"`
+	NodeID string `json:"node_id,omitempty"`
+	Body   string `json:"body"`
+}
+
+// ProjectItem is an issue's item in a project (v2)
+type ProjectItem struct {
+	ID            string
+	ProjectNumber int
+	ProjectTitle  string
+}
+
+type Label struct {
+	Name string `json:"name"`
+}
+
+type User struct {
+	Login string `json:"login"`
+}
+
+type Milestone struct {
+	Number int    `json:"number"`
+	Title  string `json:"title,omitempty"`
+}
+
+// DesiredState returns the state the spec wants the issue in, open unless it says closed
+func DesiredState(ghIssueSpec examplev1alpha1.GitHubIssueSpec) string {
+	if ghIssueSpec.State == examplev1alpha1.IssueStateClosed {
+		return string(examplev1alpha1.IssueStateClosed)
+	}
+	return string(examplev1alpha1.IssueStateOpen)
+}
+
+// IsPullRequest returns whether github listed a pull request rather than an issue
+func (i *Issue) IsPullRequest() bool {
+	return i.PullRequest != nil
+}
+
+// LabelNames returns the names of the issue's labels
+func (i *Issue) LabelNames() []string {
+	var names []string
+	for _, label := range i.Labels {
+		names = append(names, label.Name)
+	}
+	return names
+}
+
+// AssigneeLogins returns the logins of the issue's assignees
+func (i *Issue) AssigneeLogins() []string {
+	var logins []string
+	for _, assignee := range i.Assignees {
+		logins = append(logins, assignee.Login)
+	}
+	return logins
+}
+
+// AuthorLogin returns the login of the user who opened the issue
+func (i *Issue) AuthorLogin() string {
+	if i.Author == nil {
+		return ""
+	}
+	return i.Author.Login
+}
+
+// MilestoneNumber returns the number of the issue's milestone, 0 when it has none
+func (i *Issue) MilestoneNumber() int {
+	if i.Milestone == nil {
+		return 0
+	}
+	return i.Milestone.Number
 }
@@ -0,0 +1,90 @@
+package github
+
+import (
+	"container/list"
+	"net/http"
+	"sync"
+)
+
+// DefaultCacheSize is the number of responses a ResponseCache keeps when no size is given
+const DefaultCacheSize = 1000
+
+// ResponseCache keeps github's responses to GET requests along with their ETag, so that the requests are sent
+// again with If-None-Match and a 304 Not Modified (which github doesn't count against the rate limit) is answered
+// from the cache. responses are kept by url and credential, up to a bounded number evicting the least recently
+// used. it is safe to share between reconciles
+type ResponseCache struct {
+	size int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+// cachedResponse is a response body with the ETag github sent it with, and the headers needed to use it again
+type cachedResponse struct {
+	key  string
+	etag string
+	link string
+	body []byte
+}
+
+// NewResponseCache returns a cache keeping up to size responses, DefaultCacheSize when size isn't positive
+func NewResponseCache(size int) *ResponseCache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &ResponseCache{
+		size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// Len returns the number of responses in the cache
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// get : the response cached for the url and token, if any
+func (c *ResponseCache) get(apiURL, token string) (*cachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[cacheKey(apiURL, token)]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cachedResponse), true
+}
+
+// add : keep the body of a successful response to a request with the url and token, responses without an ETag
+// can't be asked for again conditionally and aren't kept
+func (c *ResponseCache) add(apiURL, token string, resp *http.Response, body []byte) {
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return
+	}
+	entry := &cachedResponse{key: cacheKey(apiURL, token), etag: etag, link: resp.Header.Get("Link"), body: body}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[entry.key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// cacheKey : responses are kept per credential, as what a token sees depends on its permissions. like the rate
+// limit tracker, the cache keeps a digest of the token rather than the token itself
+func cacheKey(apiURL, token string) string {
+	return credentialKey(token) + " " + apiURL
+}
@@ -0,0 +1,149 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+)
+
+func etagResponse(etag string) *http.Response {
+	return &http.Response{Header: http.Header{"Etag": []string{etag}}}
+}
+
+func TestResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	//given a cache of two responses holding a and b, where a was used last
+	cache := NewResponseCache(2)
+	cache.add("a", "token", etagResponse(`"a"`), []byte("a"))
+	cache.add("b", "token", etagResponse(`"b"`), []byte("b"))
+	cache.get("a", "token")
+
+	//when adding a third response
+	cache.add("c", "token", etagResponse(`"c"`), []byte("c"))
+
+	//then b is evicted
+	if _, ok := cache.get("b", "token"); ok {
+		t.Errorf("Expected b to be evicted")
+	}
+	if _, ok := cache.get("a", "token"); !ok {
+		t.Errorf("Expected a to be kept")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 responses but got: %d", cache.Len())
+	}
+}
+
+func TestResponseCacheKeysByCredential(t *testing.T) {
+	//given a response cached for a token
+	cache := NewResponseCache(10)
+	cache.add("a", "token", etagResponse(`"a"`), []byte("a"))
+
+	//when looking it up with another token
+	_, ok := cache.get("a", "other-token")
+
+	//then it isn't found
+	if ok {
+		t.Errorf("Expected the response to be kept for its own token only")
+	}
+}
+
+func TestResponseCacheSkipsResponsesWithoutETag(t *testing.T) {
+	//given a cache
+	cache := NewResponseCache(10)
+
+	//when adding a response without an ETag
+	cache.add("a", "token", &http.Response{Header: http.Header{}}, []byte("a"))
+
+	//then it isn't kept
+	if cache.Len() != 0 {
+		t.Errorf("Expected no responses but got: %d", cache.Len())
+	}
+}
+
+func TestNotModifiedIsServedFromCache(t *testing.T) {
+	//given a github answering with an ETag, and 304 when the request carries it
+	var conditional int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(1700000000))
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(`{"number":7,"title":"testIssue"}`))
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+	c.Cache = NewResponseCache(10)
+	c.RateLimits = NewRateLimitTracker(0)
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+	hits, misses := testutil.ToFloat64(cacheLookups.WithLabelValues("hit")), testutil.ToFloat64(cacheLookups.WithLabelValues("miss"))
+
+	//when getting the issue twice
+	_, firstErr := c.GetIssue(context.Background(), spec, "7", StaticToken("token"))
+	issue, secondErr := c.GetIssue(context.Background(), spec, "7", StaticToken("token"))
+
+	//then the second request is conditional and its 304 answered from the cache
+	if firstErr != nil || secondErr != nil {
+		t.Fatalf("Expected no errors but got: %v, %v", firstErr, secondErr)
+	}
+	if conditional != 1 || issue.Title != "testIssue" {
+		t.Errorf("Expected the cached issue after a conditional request but got %d conditional requests and: %+v",
+			conditional, issue)
+	}
+	if got := testutil.ToFloat64(cacheLookups.WithLabelValues("hit")) - hits; got != 1 {
+		t.Errorf("Expected one hit but got: %v", got)
+	}
+	if got := testutil.ToFloat64(cacheLookups.WithLabelValues("miss")) - misses; got != 1 {
+		t.Errorf("Expected one miss but got: %v", got)
+	}
+}
+
+func TestNotModifiedPagesAreFollowed(t *testing.T) {
+	//given a repository whose issue pages don't change, and a github that drops the Link header on 304
+	pages := threePagesOfIssues()
+	var served int
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == 0 {
+			page = 1
+		}
+		etag := fmt.Sprintf(`"page%d"`, page)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		served++
+		w.Header().Set("ETag", etag)
+		if page < len(pages) {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/repos/testUser/testRepo/issues?state=all&per_page=100&page=%d>; rel="next"`,
+				server.URL, page+1))
+		}
+		_, _ = w.Write([]byte(fmt.Sprintf(`[{"title":"page %d","number":%d}]`, page, page)))
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+	c.Cache = NewResponseCache(10)
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "page 3"}
+
+	//when looking for an issue on the last page twice
+	_, firstErr := c.FindIssue(context.Background(), spec, StaticToken("token"))
+	issue, secondErr := c.FindIssue(context.Background(), spec, StaticToken("token"))
+
+	//then the second search follows the cached pages without downloading them again
+	if firstErr != nil || secondErr != nil {
+		t.Fatalf("Expected no errors but got: %v, %v", firstErr, secondErr)
+	}
+	if issue.IssueNumber != "3" || served != 3 {
+		t.Errorf("Expected issue 3 with every page downloaded once but got: %s, %d downloads", issue.IssueNumber, served)
+	}
+}
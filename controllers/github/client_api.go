@@ -2,165 +2,354 @@ package github
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
-const UrlPrefix = "https://api.github.com/repos/"
+// APIBaseURL is the root of the github.com api. github enterprise server serves it under
+// https://<host>/api/v3/
+const APIBaseURL = "https://api.github.com/"
 
 type ClientAPI struct {
-	httpClient http.Client
-	token      string
+	// HTTPClient sends the requests, defaults to http.DefaultClient. see NewTransport for proxies and custom CAs
+	HTTPClient *http.Client
+	// Timeout of every request, DefaultRequestTimeout when it isn't set
+	Timeout time.Duration
+	// BaseURL of the github api, defaults to APIBaseURL. a GitHubIssueSpec's baseURL overrides it
+	BaseURL string
+	// RateLimits (optional) stops calls once github's rate limit is exhausted, share it between clients
+	// using the same credentials
+	RateLimits *RateLimitTracker
+	// Cache (optional) sends GET requests conditionally and answers them from the cache when github replies
+	// 304 Not Modified, share it between clients
+	Cache *ResponseCache
 }
 
-//func NewGithubClient() ClientAPI {
-//	return ClientAPI{
-//		httpClient: http.Client{},
-//		token:      os.Getenv("GITHUB_TOKEN"),
-//	}
-//}
-
 // NewIssue https://vorozhko.net/create-github-issue-ticket-with-golang
 // specify data fields for new github issue submission
 
 type NewIssue struct {
-	Title       string `json:"title"`
-	Description string `json:"body"`
+	Title       string   `json:"title"`
+	Description string   `json:"body"`
+	Labels      []string `json:"labels,omitempty"`
+	Assignees   []string `json:"assignees,omitempty"`
+	Milestone   *int     `json:"milestone,omitempty"`
+	State       string   `json:"state,omitempty"`
+	StateReason string   `json:"state_reason,omitempty"`
 }
 
-type Repo struct {
-	Repo  string `json:"repo"`
-	Owner string `json:"owner"`
+// newIssueFromSpec : the fields of the spec the operator manages on github
+func newIssueFromSpec(ghIssueSpec examplev1alpha1.GitHubIssueSpec) NewIssue {
+	return NewIssue{
+		Title:       ghIssueSpec.Title,
+		Description: ghIssueSpec.Description,
+		Labels:      ghIssueSpec.Labels,
+		Assignees:   ghIssueSpec.Assignees,
+		Milestone:   ghIssueSpec.Milestone,
+	}
 }
 
-func (c *ClientAPI) FindIssue(ghIssueSpec examplev1alpha1.GitHubIssueSpec, token string) (*Issue, error) {
-	apiURL := "https://api.github.com/repos/" + ghIssueSpec.Repo + "/issues?state=all"
-	// split ownerRepo to owner and repository
-	ownerAndRepo := strings.Split(ghIssueSpec.Repo, "/")
-	// title is the only required field
-	repoData := Repo{Repo: ownerAndRepo[0], Owner: ownerAndRepo[1]}
-	// make it json
-	jsonData, _ := json.Marshal(repoData)
-	// creating client to set custom headers for Authorization
-	req, _ := http.NewRequest("GET", apiURL, bytes.NewReader(jsonData))
-	req.Header.Set("Authorization", "token "+token)
-	resp, err := c.httpClient.Do(req)
+// FindIssue : look for the issue with the title of the spec, following github's pagination over all the
+// repository issues
+func (c *ClientAPI) FindIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, tokenSource TokenSource) (*Issue, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	body, _ := ioutil.ReadAll(resp.Body)
-	// print body as it may contain hints in case of errors
-	//fmt.Println(string(body))
+	apiURL := c.reposURL(ghIssueSpec) + "/issues?state=all&per_page=100"
+	for apiURL != "" {
+		var issues []Issue
+		resp, err := c.do(ctx, "GET", apiURL, token, nil, &issues)
+		if err != nil {
+			return nil, err
+		}
 
-	var issues []Issue
-	err = json.Unmarshal(body, &issues)
+		// loop over issues titles and look for the title given to the function. pull requests are listed
+		// along with the issues, they're never managed
+		for _, issue := range issues {
+			if issue.Title == ghIssueSpec.Title && !issue.IsPullRequest() {
+				return &issue, nil
+			}
+		}
+		apiURL = nextPageURL(resp.Header.Get("Link"))
+	}
+
+	return nil, ErrTitleNotFound
+}
 
+// FindIssueByMarker : go over the issues updated since the given time, newest first, for the marker
+func (c *ClientAPI) FindIssueByMarker(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, marker string,
+	since time.Time, tokenSource TokenSource) (*Issue, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
 	if err != nil {
 		return nil, err
 	}
+	apiURL := c.reposURL(ghIssueSpec) + "/issues?state=all&sort=created&direction=desc&per_page=100"
+	if !since.IsZero() {
+		apiURL += "&since=" + url.QueryEscape(since.UTC().Format(time.RFC3339))
+	}
+	for apiURL != "" {
+		var issues []Issue
+		resp, err := c.do(ctx, "GET", apiURL, token, nil, &issues)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			if strings.Contains(issue.Description, marker) && !issue.IsPullRequest() {
+				return &issue, nil
+			}
+		}
+		apiURL = nextPageURL(resp.Header.Get("Link"))
+	}
+	return nil, ErrMarkerNotFound
+}
+
+// reposURL : the repository url of the spec under the api root of the spec, or of the client
+func (c *ClientAPI) reposURL(ghIssueSpec examplev1alpha1.GitHubIssueSpec) string {
+	baseURL := ghIssueSpec.BaseURL
+	if baseURL == "" {
+		baseURL = c.BaseURL
+	}
+	return NormalizeBaseURL(baseURL) + "repos/" + ghIssueSpec.Repo
+}
+
+// NormalizeBaseURL returns APIBaseURL for an empty url, and makes sure the url ends with a slash
+func NormalizeBaseURL(baseURL string) string {
+	if baseURL == "" {
+		return APIBaseURL
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/"
+}
 
-	// loop over issues titles and look for the title given to the function
-	for _, issue := range issues {
-		if issue.Title == ghIssueSpec.Title {
-			fmt.Printf("!!!!!1repo: %s", issue.Repo)
-			return &issue, nil
+// nextPageURL : extract the rel="next" url from github's Link header, empty on the last page
+func nextPageURL(linkHeader string) string {
+	for _, link := range strings.Split(linkHeader, ",") {
+		parts := strings.Split(link, ";")
+		if len(parts) < 2 {
+			continue
+		}
+		for _, param := range parts[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return strings.Trim(strings.TrimSpace(parts[0]), "<>")
+			}
 		}
 	}
-	err = fmt.Errorf(TitleNotFound)
+	return ""
+}
 
-	return nil, err
+// GetIssue : fetch a github issue by its number
+func (c *ClientAPI) GetIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string, tokenSource TokenSource) (*Issue, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	apiURL := c.reposURL(ghIssueSpec) + "/issues/" + issueNumber
+	var issue *Issue
+	if _, err = c.do(ctx, "GET", apiURL, token, nil, &issue); err != nil {
+		return nil, err
+	}
+	return issue, nil
 }
 
 // function I copied from:
 // https://vorozhko.net/create-github-issue-ticket-with-golang
-func (c *ClientAPI) Create(ghIssueSpec examplev1alpha1.GitHubIssueSpec, token string) (*Issue, error) {
-	apiURL := "https://api.github.com/repos/" + ghIssueSpec.Repo + "/issues"
-	// title is the only required field
-	issueData := NewIssue{Title: ghIssueSpec.Title, Description: ghIssueSpec.Description}
-	// make it json
-	jsonData, _ := json.Marshal(issueData)
-	// set custom headers for Authorization
-	req, _ := http.NewRequest("POST", apiURL, bytes.NewReader(jsonData))
-
-	req.Header.Set("Authorization", "token "+token)
-	resp, err := c.httpClient.Do(req)
+func (c *ClientAPI) Create(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, tokenSource TokenSource) (*Issue, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	apiURL := c.reposURL(ghIssueSpec) + "/issues"
+	// title is the only required field
+	issueData := newIssueFromSpec(ghIssueSpec)
+	var issue *Issue
+	if _, err = c.do(ctx, "POST", apiURL, token, issueData, &issue); err != nil {
+		return nil, err
+	}
+	return issue, nil
+}
 
-	if resp.StatusCode != http.StatusCreated {
-		fmt.Printf("Response code is is %d\n", resp.StatusCode)
-		body, _ := ioutil.ReadAll(resp.Body)
-		//print body as it may contain hints in case of errors
-		fmt.Println(string(body))
+// Edit : set the managed fields of the spec (including its state) on the github issue, and return the issue as
+// github saved it
+func (c *ClientAPI) Edit(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string, tokenSource TokenSource) (*Issue, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
 		return nil, err
 	}
-	body, _ := ioutil.ReadAll(resp.Body)
+	apiURL := c.reposURL(ghIssueSpec) + "/issues/" + issueNumber
+	issueData := newIssueFromSpec(ghIssueSpec)
+	issueData.State = DesiredState(ghIssueSpec)
+	if issueData.State == string(examplev1alpha1.IssueStateClosed) {
+		issueData.StateReason = string(ghIssueSpec.StateReason)
+	}
 	var issue *Issue
-	err = json.Unmarshal(body, &issue)
-
-	//fmt.Println(string(body))
-	return issue, err
+	if _, err = c.do(ctx, "PATCH", apiURL, token, issueData, &issue); err != nil {
+		return nil, err
+	}
+	return issue, nil
 }
 
-func (c *ClientAPI) Edit(ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, token string) error {
-	apiURL := "https://api.github.com/repos/" + ghIssueSpec.Repo + "/issues/" + issueNumber
-	// title is the only required field
-	issueData := Issue{Repo: ghIssueSpec.Repo, Title: ghIssueSpec.Title, Description: ghIssueSpec.Description,
-		IssueNumber: json.Number(issueNumber)}
-	// make it json
-	jsonData, _ := json.Marshal(issueData)
-	// creating client to set custom headers for Authorization
-	req, _ := http.NewRequest("PATCH", apiURL, bytes.NewReader(jsonData))
-	req.Header.Set("Authorization", "token "+token)
-	resp, err := c.httpClient.Do(req)
+// Close : close github issue
+func (c *ClientAPI) Close(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string, tokenSource TokenSource) error {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	apiURL := c.reposURL(ghIssueSpec) + "/issues/" + issueNumber
+	issueData := newIssueFromSpec(ghIssueSpec)
+	issueData.State = "closed"
+	issueData.StateReason = string(ghIssueSpec.StateReason)
+	_, err = c.do(ctx, "PATCH", apiURL, token, issueData, nil)
+	return err
+}
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Response code is %d\n", resp.StatusCode)
-		body, _ := ioutil.ReadAll(resp.Body)
-		// print body as it may contain hints in case of errors
-		fmt.Println(string(body))
-		return err
+// CreateComment : post a comment on github issue
+func (c *ClientAPI) CreateComment(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, body string,
+	tokenSource TokenSource) (*Comment, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
 	}
+	apiURL := c.reposURL(ghIssueSpec) + "/issues/" + issueNumber + "/comments"
+	var comment *Comment
+	if _, err = c.do(ctx, "POST", apiURL, token, Comment{Body: body}, &comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
 
-	return nil
+// ListComments : all the comments of a github issue, following github's pagination
+func (c *ClientAPI) ListComments(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string,
+	tokenSource TokenSource) ([]*Comment, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	var comments []*Comment
+	apiURL := c.reposURL(ghIssueSpec) + "/issues/" + issueNumber + "/comments?per_page=100"
+	for apiURL != "" {
+		var page []*Comment
+		resp, err := c.do(ctx, "GET", apiURL, token, nil, &page)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, page...)
+		apiURL = nextPageURL(resp.Header.Get("Link"))
+	}
+	return comments, nil
 }
 
-// Close : close github issue
-func (c *ClientAPI) Close(ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, token string) error {
-	apiURL := "https://api.github.com/repos/" + ghIssueSpec.Repo + "/issues/" + issueNumber
-	// title is the only required field
-	issueData := Issue{Repo: ghIssueSpec.Repo, Title: ghIssueSpec.Title, Description: ghIssueSpec.Description,
-		IssueNumber: json.Number(issueNumber), State: "closed"}
-	// make it json
-	jsonData, _ := json.Marshal(issueData)
-	// creating client to set custom headers for Authorization
-	client := &http.Client{}
-	req, _ := http.NewRequest("PATCH", apiURL, bytes.NewReader(jsonData))
+// EditComment : replace the body of a comment
+func (c *ClientAPI) EditComment(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, comment *Comment, body string,
+	tokenSource TokenSource) (*Comment, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	apiURL := c.reposURL(ghIssueSpec) + "/issues/comments/" + strconv.FormatInt(comment.ID, 10)
+	var edited *Comment
+	if _, err = c.do(ctx, "PATCH", apiURL, token, Comment{Body: body}, &edited); err != nil {
+		return nil, err
+	}
+	return edited, nil
+}
 
-	req.Header.Set("Authorization", "token "+token)
-	resp, err := client.Do(req)
+// DeleteComment : delete a comment
+func (c *ClientAPI) DeleteComment(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, comment *Comment,
+	tokenSource TokenSource) error {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
 	if err != nil {
 		return err
 	}
+	apiURL := c.reposURL(ghIssueSpec) + "/issues/comments/" + strconv.FormatInt(comment.ID, 10)
+	_, err = c.do(ctx, "DELETE", apiURL, token, nil, nil)
+	return err
+}
+
+// do : send a request to the github api with the token as authorization. payload (if not nil) is sent as the
+// json body and a successful response body is decoded into result (if not nil). any non 2xx response is
+// returned as an *APIError, and a *RateLimitError is returned without calling github when the rate limit
+// is known to be exhausted
+func (c *ClientAPI) do(ctx context.Context, method, apiURL, token string, payload, result interface{}) (*http.Response, error) {
+	if c.RateLimits != nil {
+		if err := c.RateLimits.Check(token, rateLimitResource(apiURL, nil)); err != nil {
+			return nil, err
+		}
+	}
+	var body io.Reader
+	if payload != nil {
+		jsonData, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(jsonData)
+	}
+	ctx, cancel := requestContext(ctx, c.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, apiURL, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Authorization", "token "+token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	cacheable := method == "GET" && c.Cache != nil
+	var cached *cachedResponse
+	if cacheable {
+		if cached, _ = c.Cache.get(apiURL, token); cached != nil {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+	}
+
+	start := time.Now()
+	resp, err := httpClientOrDefault(c.HTTPClient).Do(req)
+	observeRequest(method, apiURL, token, resp, start)
+	if err != nil {
+		return nil, err
+	}
 	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		fmt.Printf("Response code is %d\n", resp.StatusCode)
-		body, _ := ioutil.ReadAll(resp.Body)
-		// print body as it may contain hints in case of errors
-		fmt.Println(string(body))
-		return err
+	// a 304 isn't counted against the rate limit by github, its headers still tell the tracker what's left
+	notModified := cached != nil && resp.StatusCode == http.StatusNotModified
+	if cacheable {
+		observeCacheLookup(notModified)
+	}
+	if notModified {
+		respBody = cached.body
+		if cached.link != "" {
+			resp.Header.Set("Link", cached.link)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := newAPIError(resp, respBody)
+		if c.RateLimits != nil {
+			c.RateLimits.Update(token, rateLimitResource(apiURL, resp), resp, apiErr)
+		}
+		return resp, apiErr
+	}
+	if c.RateLimits != nil {
+		c.RateLimits.Update(token, rateLimitResource(apiURL, resp), resp, nil)
+	}
+	if cacheable && !notModified {
+		c.Cache.add(apiURL, token, resp, respBody)
+	}
+	if result != nil {
+		if err = json.Unmarshal(respBody, result); err != nil {
+			return resp, fmt.Errorf("decoding response of %s %s: %w", method, apiURL, err)
+		}
 	}
-	return nil
+	return resp, nil
 }
@@ -0,0 +1,526 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+)
+
+func newTestClientAPI(server *httptest.Server) *ClientAPI {
+	return &ClientAPI{BaseURL: server.URL}
+}
+
+// newPagedIssuesServer serves the given pages of issues, linking each page to the next one the way github does
+func newPagedIssuesServer(t *testing.T, pages [][]Issue, requestedPages *[]int) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/testUser/testRepo/issues" {
+			t.Errorf("Expected issues path but got: %s", r.URL.Path)
+		}
+		page := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			page, _ = strconv.Atoi(p)
+		}
+		*requestedPages = append(*requestedPages, page)
+		if page < len(pages) {
+			w.Header().Set("Link", fmt.Sprintf(
+				`<%s/repos/testUser/testRepo/issues?state=all&per_page=100&page=%d>; rel="next", `+
+					`<%s/repos/testUser/testRepo/issues?state=all&per_page=100&page=%d>; rel="last"`,
+				server.URL, page+1, server.URL, len(pages)))
+		}
+		_ = json.NewEncoder(w).Encode(pages[page-1])
+	}))
+	return server
+}
+
+func threePagesOfIssues() [][]Issue {
+	return [][]Issue{
+		{{Title: "first", IssueNumber: "1"}, {Title: "second", IssueNumber: "2"}},
+		{{Title: "third", IssueNumber: "3"}, {Title: "fourth", IssueNumber: "4"}},
+		{{Title: "fifth", IssueNumber: "5"}},
+	}
+}
+
+func TestFindIssueFollowsPagination(t *testing.T) {
+	//given a repository whose issues are spread over three pages
+	var requestedPages []int
+	server := newPagedIssuesServer(t, threePagesOfIssues(), &requestedPages)
+	defer server.Close()
+	c := newTestClientAPI(server)
+
+	//when looking for an issue on the last page
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "fifth"}
+	issue, err := c.FindIssue(context.Background(), spec, StaticToken("token"))
+
+	//then all pages are read and the issue is found
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if issue.IssueNumber != "5" {
+		t.Errorf("Expected issue number 5 but got: %s", issue.IssueNumber)
+	}
+	if len(requestedPages) != 3 {
+		t.Errorf("Expected 3 pages to be requested but got: %v", requestedPages)
+	}
+}
+
+func TestFindIssueStopsOnMatch(t *testing.T) {
+	//given a repository whose issues are spread over three pages
+	var requestedPages []int
+	server := newPagedIssuesServer(t, threePagesOfIssues(), &requestedPages)
+	defer server.Close()
+	c := newTestClientAPI(server)
+
+	//when looking for an issue on the second page
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "third"}
+	issue, err := c.FindIssue(context.Background(), spec, StaticToken("token"))
+
+	//then the last page isn't requested
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if issue.IssueNumber != "3" {
+		t.Errorf("Expected issue number 3 but got: %s", issue.IssueNumber)
+	}
+	if len(requestedPages) != 2 {
+		t.Errorf("Expected 2 pages to be requested but got: %v", requestedPages)
+	}
+}
+
+func TestFindIssueSkipsPullRequests(t *testing.T) {
+	//given a repository with a pull request listed before the issue with the same title
+	pullRequest := json.RawMessage(`{"url": "https://api.github.com/repos/testUser/testRepo/pulls/1"}`)
+	pages := [][]Issue{
+		{{Title: "wanted", IssueNumber: "1", PullRequest: &pullRequest}},
+		{{Title: "wanted", IssueNumber: "2"}},
+	}
+	var requestedPages []int
+	server := newPagedIssuesServer(t, pages, &requestedPages)
+	defer server.Close()
+	c := newTestClientAPI(server)
+
+	//when looking for the title
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "wanted"}
+	issue, err := c.FindIssue(context.Background(), spec, StaticToken("token"))
+
+	//then the pull request is passed over for the issue
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if issue.IssueNumber != "2" {
+		t.Errorf("Expected issue number 2 but got: %s", issue.IssueNumber)
+	}
+}
+
+func TestFindIssueNotFoundOnAnyPage(t *testing.T) {
+	//given a repository whose issues are spread over three pages
+	var requestedPages []int
+	server := newPagedIssuesServer(t, threePagesOfIssues(), &requestedPages)
+	defer server.Close()
+	c := newTestClientAPI(server)
+
+	//when looking for a title that doesn't exist
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "missing"}
+	_, err := c.FindIssue(context.Background(), spec, StaticToken("token"))
+
+	//then every page is read and ErrTitleNotFound is returned
+	if !errors.Is(err, ErrTitleNotFound) {
+		t.Errorf("Expected ErrTitleNotFound but got: %v", err)
+	}
+	if len(requestedPages) != 3 {
+		t.Errorf("Expected 3 pages to be requested but got: %v", requestedPages)
+	}
+}
+
+func TestFindIssueByMarker(t *testing.T) {
+	//given a repository where a page of recent issues holds the marker in the body of issue #2
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		_ = json.NewEncoder(w).Encode([]Issue{
+			{Title: "third", IssueNumber: "3", Description: "someone else's"},
+			{Title: "renamed", IssueNumber: "2", Description: "testing...\n\n<!-- marker -->"},
+		})
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+
+	//when looking for the marker among the issues updated since a given time
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+	since := time.Date(2021, 5, 30, 9, 0, 0, 0, time.UTC)
+	issue, err := c.FindIssueByMarker(context.Background(), spec, "<!-- marker -->", since, StaticToken("token"))
+
+	//then the newest issues updated since then are listed and the one holding the marker is returned
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if issue.IssueNumber != "2" {
+		t.Errorf("Expected issue 2 but got: %s", issue.IssueNumber)
+	}
+	if query.Get("since") != "2021-05-30T09:00:00Z" || query.Get("sort") != "created" || query.Get("direction") != "desc" {
+		t.Errorf("Expected the newest issues updated since the given time but got: %v", query)
+	}
+
+	//and a marker no issue holds isn't found
+	if _, err = c.FindIssueByMarker(context.Background(), spec, "<!-- other -->", since, StaticToken("token")); !errors.Is(err, ErrMarkerNotFound) {
+		t.Errorf("Expected ErrMarkerNotFound but got: %v", err)
+	}
+}
+
+func TestNextPageURL(t *testing.T) {
+	link := `<https://api.github.com/repositories/1/issues?page=2>; rel="next", ` +
+		`<https://api.github.com/repositories/1/issues?page=5>; rel="last"`
+	if next := nextPageURL(link); next != "https://api.github.com/repositories/1/issues?page=2" {
+		t.Errorf("Expected the next page url but got: %s", next)
+	}
+	last := `<https://api.github.com/repositories/1/issues?page=1>; rel="prev", ` +
+		`<https://api.github.com/repositories/1/issues?page=1>; rel="first"`
+	if next := nextPageURL(last); next != "" {
+		t.Errorf("Expected no next page but got: %s", next)
+	}
+}
+
+func TestFailedResponsesReturnAPIError(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		headers  map[string]string
+		body     string
+		sentinel error
+	}{
+		{"unauthorized", http.StatusUnauthorized, nil, `{"message":"Bad credentials"}`, ErrUnauthorized},
+		{"not found", http.StatusNotFound, nil, `{"message":"Not Found"}`, ErrNotFound},
+		{"validation", http.StatusUnprocessableEntity, nil,
+			`{"message":"Validation Failed","errors":[{"resource":"Issue","field":"title","code":"missing_field"}]}`,
+			ErrValidation},
+		{"forbidden", http.StatusForbidden, nil, `{"message":"Resource not accessible by integration"}`, ErrForbidden},
+		{"primary rate limit", http.StatusForbidden,
+			map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1700000000"},
+			`{"message":"API rate limit exceeded"}`, ErrRateLimited},
+		{"secondary rate limit", http.StatusForbidden, map[string]string{"Retry-After": "60"},
+			`{"message":"You have exceeded a secondary rate limit"}`, ErrRateLimited},
+	}
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "title", Description: "body"}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//given a github that fails every request
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, value := range tt.headers {
+					w.Header().Set(key, value)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			c := newTestClientAPI(server)
+
+			//when calling every method of the client
+			_, createErr := c.Create(context.Background(), spec, StaticToken("token"))
+			_, editErr := c.Edit(context.Background(), spec, "1", StaticToken("token"))
+			closeErr := c.Close(context.Background(), spec, "1", StaticToken("token"))
+			_, getErr := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+			_, findErr := c.FindIssue(context.Background(), spec, StaticToken("token"))
+
+			//then each of them returns an *APIError matching the expected sentinel
+			for _, err := range []error{createErr, editErr, closeErr, getErr, findErr} {
+				var apiErr *APIError
+				if !errors.As(err, &apiErr) {
+					t.Fatalf("Expected an *APIError but got: %v", err)
+				}
+				if apiErr.StatusCode != tt.status {
+					t.Errorf("Expected status code %d but got: %d", tt.status, apiErr.StatusCode)
+				}
+				if !errors.Is(err, tt.sentinel) {
+					t.Errorf("Expected error to match %v but got: %v", tt.sentinel, err)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIErrorRateLimitHeaders(t *testing.T) {
+	//given a github that answers with an exhausted rate limit
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "1700000000")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message":"API rate limit exceeded","documentation_url":"https://docs.github.com/rest"}`))
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+
+	//when creating an issue
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "title"}
+	_, err := c.Create(context.Background(), spec, StaticToken("token"))
+
+	//then the error carries github's message and the rate limit headers
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *APIError but got: %v", err)
+	}
+	if apiErr.Message != "API rate limit exceeded" || apiErr.DocumentationURL != "https://docs.github.com/rest" {
+		t.Errorf("Expected github's message and documentation url but got: %q %q", apiErr.Message, apiErr.DocumentationURL)
+	}
+	if apiErr.RateLimitLimit != 5000 || apiErr.RateLimitRemaining != 0 || apiErr.RateLimitReset.Unix() != 1700000000 {
+		t.Errorf("Expected rate limit headers to be parsed but got: %+v", apiErr)
+	}
+	if errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected a rate limit error not to match ErrForbidden")
+	}
+}
+
+func TestRateLimitTrackerStopsCallsBeforeQuotaRunsOut(t *testing.T) {
+	//given a github that reports few remaining requests and a tracker that keeps 2 of them in reserve
+	reset := time.Now().Add(time.Hour).Unix()
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(3-calls))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		_, _ = w.Write([]byte(`{"number":1,"title":"title"}`))
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+	c.RateLimits = NewRateLimitTracker(2)
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "title"}
+
+	//when calling github until the reserve is reached
+	_, firstErr := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+	_, secondErr := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+
+	//then the second call is stopped before reaching github and reports the reset time
+	if firstErr != nil {
+		t.Fatalf("Expected no error but got an error: %v", firstErr)
+	}
+	var limitErr *RateLimitError
+	if !errors.As(secondErr, &limitErr) {
+		t.Fatalf("Expected a *RateLimitError but got: %v", secondErr)
+	}
+	if limitErr.Reset.Unix() != reset {
+		t.Errorf("Expected reset at %d but got: %d", reset, limitErr.Reset.Unix())
+	}
+	if calls != 1 {
+		t.Errorf("Expected github to be called once but got: %d", calls)
+	}
+	//and other credentials are not affected
+	if _, err := c.GetIssue(context.Background(), spec, "1", StaticToken("other token")); err != nil {
+		t.Errorf("Expected no error for another token but got: %v", err)
+	}
+}
+
+func TestRateLimitTrackerHonorsSecondaryLimit(t *testing.T) {
+	//given a github that answers with a secondary rate limit
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"message":"You have exceeded a secondary rate limit"}`))
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+	c.RateLimits = NewRateLimitTracker(0)
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "title"}
+
+	//when calling github twice
+	_, firstErr := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+	_, secondErr := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+
+	//then both calls wait for about the Retry-After and github is called only once
+	for _, err := range []error{firstErr, secondErr} {
+		wait, limited := RateLimitWait(err)
+		if !limited {
+			t.Fatalf("Expected a rate limit error but got: %v", err)
+		}
+		if wait < 25*time.Second || wait > 32*time.Second {
+			t.Errorf("Expected to wait about 30s but got: %v", wait)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected github to be called once but got: %d", calls)
+	}
+}
+
+func TestRateLimitTrackerKeepsRestAndGraphQLApart(t *testing.T) {
+	//given a github whose rest quota of the token ran out while its graphql quota didn't
+	reset := time.Now().Add(time.Hour).Unix()
+	restCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+		if r.URL.Path == "/graphql" {
+			w.Header().Set("X-RateLimit-Resource", "graphql")
+			w.Header().Set("X-RateLimit-Remaining", "4999")
+			_, _ = w.Write([]byte(`{"data":{"node":{"number":1,"repository":{"nameWithOwner":"testUser/testRepo"}}}}`))
+			return
+		}
+		restCalls++
+		w.Header().Set("X-RateLimit-Resource", "core")
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		_, _ = w.Write([]byte(`{"number":1,"title":"title"}`))
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+	c.RateLimits = NewRateLimitTracker(0)
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "title"}
+	token := StaticToken("mixed-token")
+
+	//when calling the rest api, then graphql, then the rest api again
+	_, restErr := c.GetIssue(context.Background(), spec, "1", token)
+	_, _, graphQLErr := c.LocateIssue(context.Background(), spec, "I_1", token)
+	_, limitedErr := c.GetIssue(context.Background(), spec, "1", token)
+
+	//then graphql is called with its own quota, which doesn't hide that the rest quota ran out
+	if restErr != nil || graphQLErr != nil {
+		t.Fatalf("Expected no error but got: %v, %v", restErr, graphQLErr)
+	}
+	if !errors.Is(limitedErr, ErrRateLimited) || restCalls != 1 {
+		t.Errorf("Expected the rest call to be stopped but got: %v after %d calls", limitedErr, restCalls)
+	}
+	//and each quota has its own series
+	credential := credentialKey("mixed-token")
+	if got := testutil.ToFloat64(rateLimitRemaining.WithLabelValues(credential, "core")); got != 0 {
+		t.Errorf("Expected no rest requests remaining but got: %v", got)
+	}
+	if got := testutil.ToFloat64(rateLimitRemaining.WithLabelValues(credential, "graphql")); got != 4999 {
+		t.Errorf("Expected 4999 graphql requests remaining but got: %v", got)
+	}
+}
+
+func TestSpecBaseURLOverridesClient(t *testing.T) {
+	//given a client for github.com and a spec pointing to an enterprise server
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		_, _ = w.Write([]byte(`{"number":1,"title":"title"}`))
+	}))
+	defer server.Close()
+	c := &ClientAPI{}
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", BaseURL: server.URL + "/api/v3"}
+
+	//when fetching an issue
+	_, err := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+
+	//then the enterprise server is called under its api path
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if requestedPath != "/api/v3/repos/testUser/testRepo/issues/1" {
+		t.Errorf("Expected the enterprise api path but got: %s", requestedPath)
+	}
+}
+
+func TestCreateSendsLabelsAssigneesAndMilestone(t *testing.T) {
+	//given a github that records the created issue
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&sent)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"number":1,"title":"title","labels":[{"name":"bug"}],"assignees":[{"login":"a"}],` +
+			`"milestone":{"number":2}}`))
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+	milestone := 2
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "title", Description: "body",
+		Labels: []string{"bug"}, Assignees: []string{"a"}, Milestone: &milestone}
+
+	//when creating the issue
+	issue, err := c.Create(context.Background(), spec, StaticToken("token"))
+
+	//then the managed fields are sent and read back from the response
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if fmt.Sprint(sent["labels"]) != "[bug]" || fmt.Sprint(sent["assignees"]) != "[a]" || sent["milestone"] != float64(2) {
+		t.Errorf("Expected labels, assignees and milestone in the request but got: %v", sent)
+	}
+	if fmt.Sprint(issue.LabelNames()) != "[bug]" || fmt.Sprint(issue.AssigneeLogins()) != "[a]" ||
+		issue.MilestoneNumber() != 2 {
+		t.Errorf("Expected labels, assignees and milestone in the issue but got: %+v", issue)
+	}
+}
+
+func TestCommentRequests(t *testing.T) {
+	//given a github that records the requests it gets
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method {
+		case "GET":
+			if r.URL.Query().Get("page") == "" {
+				w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?per_page=100&page=2>; rel="next"`, r.Host, r.URL.Path))
+				_, _ = w.Write([]byte(`[{"id":1,"body":"first"}]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[{"id":2,"body":"second"}]`))
+		case "PATCH":
+			_, _ = w.Write([]byte(`{"id":2,"body":"edited"}`))
+		case "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+
+	//when listing, editing and deleting comments
+	comments, listErr := c.ListComments(context.Background(), spec, "7", StaticToken("token"))
+	edited, editErr := c.EditComment(context.Background(), spec, comments[1], "edited", StaticToken("token"))
+	deleteErr := c.DeleteComment(context.Background(), spec, comments[1], StaticToken("token"))
+
+	//then every page is listed and the comments are addressed by id
+	if listErr != nil || editErr != nil || deleteErr != nil {
+		t.Fatalf("Expected no errors but got: %v, %v, %v", listErr, editErr, deleteErr)
+	}
+	if len(comments) != 2 || comments[1].Body != "second" || edited.Body != "edited" {
+		t.Errorf("Expected the comments of both pages and the edited comment but got: %v, %v", comments, edited)
+	}
+	expected := []string{
+		"GET /repos/testUser/testRepo/issues/7/comments",
+		"GET /repos/testUser/testRepo/issues/7/comments",
+		"PATCH /repos/testUser/testRepo/issues/comments/2",
+		"DELETE /repos/testUser/testRepo/issues/comments/2",
+	}
+	if fmt.Sprint(requests) != fmt.Sprint(expected) {
+		t.Errorf("Expected requests %v but got: %v", expected, requests)
+	}
+}
+
+func TestGetIssueReadsDetails(t *testing.T) {
+	//given a github serving a closed issue
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"number":1,"title":"title","state":"closed","user":{"login":"octocat"},"comments":3,` +
+			`"created_at":"2021-05-30T10:00:00Z","closed_at":"2021-05-31T07:49:28Z","html_url":"https://github.com/a/b/issues/1"}`))
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+
+	//when fetching the issue
+	issue, err := c.GetIssue(context.Background(), examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}, "1", StaticToken("token"))
+
+	//then its author, comment count and timestamps are read
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if issue.AuthorLogin() != "octocat" || issue.Comments != 3 {
+		t.Errorf("Expected the author and comment count but got: %s, %d", issue.AuthorLogin(), issue.Comments)
+	}
+	if issue.CreatedAt == nil || !issue.CreatedAt.Equal(time.Date(2021, 5, 30, 10, 0, 0, 0, time.UTC)) ||
+		issue.ClosedAt == nil || !issue.ClosedAt.Equal(time.Date(2021, 5, 31, 7, 49, 28, 0, time.UTC)) {
+		t.Errorf("Expected the creation and closing times but got: %v, %v", issue.CreatedAt, issue.ClosedAt)
+	}
+}
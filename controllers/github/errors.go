@@ -0,0 +1,118 @@
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sentinel errors callers can match with errors.Is, an *APIError matches the one fitting its status code
+var (
+	ErrNotFound     = errors.New("not found on github")
+	ErrUnauthorized = errors.New("unauthorized by github")
+	ErrForbidden    = errors.New("forbidden by github")
+	ErrRateLimited  = errors.New("github rate limit exceeded")
+	ErrValidation   = errors.New("github failed to validate the request")
+)
+
+// ErrTitleNotFound is returned by FindIssue when no issue in the repository has the spec's title
+var ErrTitleNotFound = fmt.Errorf("%s: %w", TitleNotFound, ErrNotFound)
+
+// ErrMarkerNotFound is returned by FindIssueByMarker when no issue in the repository has the marker in its body
+var ErrMarkerNotFound = fmt.Errorf("marker not found in any issue body: %w", ErrNotFound)
+
+// APIError is a non successful response from the github api
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	// Message and DocumentationURL are taken from github's error body
+	Message          string        `json:"message"`
+	DocumentationURL string        `json:"documentation_url"`
+	Errors           []ErrorDetail `json:"errors,omitempty"`
+	// rate limit headers of the response
+	RateLimitLimit     int
+	RateLimitRemaining int
+	RateLimitReset     time.Time
+	RetryAfter         time.Duration
+}
+
+// ErrorDetail is a single validation error github returns with 422 responses
+type ErrorDetail struct {
+	Resource string `json:"resource"`
+	Field    string `json:"field"`
+	Code     string `json:"code"`
+	Message  string `json:"message,omitempty"`
+}
+
+// newAPIError builds an APIError out of the response and its already read body
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		Method:             resp.Request.Method,
+		URL:                resp.Request.URL.String(),
+		StatusCode:         resp.StatusCode,
+		RateLimitLimit:     headerInt(resp.Header, "X-RateLimit-Limit", -1),
+		RateLimitRemaining: headerInt(resp.Header, "X-RateLimit-Remaining", -1),
+	}
+	if reset := headerInt(resp.Header, "X-RateLimit-Reset", 0); reset > 0 {
+		apiErr.RateLimitReset = time.Unix(int64(reset), 0)
+	}
+	if retryAfter := headerInt(resp.Header, "Retry-After", 0); retryAfter > 0 {
+		apiErr.RetryAfter = time.Duration(retryAfter) * time.Second
+	}
+	// the body isn't always json (e.g. errors from a proxy), keep it as the message then
+	if err := json.Unmarshal(body, apiErr); err != nil {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	message := fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Message)
+	for _, detail := range e.Errors {
+		message += fmt.Sprintf(" [%s.%s: %s %s]", detail.Resource, detail.Field, detail.Code, detail.Message)
+	}
+	if e.DocumentationURL != "" {
+		message += " (" + e.DocumentationURL + ")"
+	}
+	return message
+}
+
+// Is lets errors.Is match an APIError against the sentinel errors of this package
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound || e.StatusCode == http.StatusGone
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden && !e.isRateLimit()
+	case ErrRateLimited:
+		return e.isRateLimit()
+	case ErrValidation:
+		return e.StatusCode == http.StatusUnprocessableEntity
+	}
+	return false
+}
+
+// isRateLimit: github answers 403 or 429 both for the primary limit (no remaining requests) and for the
+// secondary (abuse) limit, which comes with a Retry-After header or says so in the message
+func (e *APIError) isRateLimit() bool {
+	if e.StatusCode != http.StatusForbidden && e.StatusCode != http.StatusTooManyRequests {
+		return false
+	}
+	return e.StatusCode == http.StatusTooManyRequests || e.RateLimitRemaining == 0 || e.RetryAfter > 0 ||
+		strings.Contains(strings.ToLower(e.Message), "rate limit")
+}
+
+func headerInt(header http.Header, key string, defaultValue int) int {
+	value, err := strconv.Atoi(header.Get(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
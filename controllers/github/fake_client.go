@@ -1,10 +1,14 @@
 package github
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const CreatError = "client fails on create"
@@ -12,10 +16,27 @@ const EditError = "client fails on Edit"
 const DeleteError = "client fails on Delete"
 const StatusUpdateError = "client fails "
 
+// FakeLogin is the author of the issues the fake client creates
+const FakeLogin = "fake-user"
+
+// fakeNow is the creation and closing time of the fake client's issues
+var fakeNow = time.Date(2021, 5, 31, 7, 49, 28, 0, time.UTC)
+
 //TODO http test (pkg), mockgen (mock generator)
 type FakeClient struct {
 	Issues []*Issue
 	Err    error
+	// RateLimitReset makes every call fail with a *RateLimitError until that time
+	RateLimitReset time.Time
+	// LastToken is the token of the latest call
+	LastToken string
+	// Repos are the repos of the calls, in order
+	Repos []string
+	// Comments posted on the issues, by issue number
+	Comments      map[string][]*Comment
+	lastCommentID int64
+	// Transfers counts the issues transferred to another repo
+	Transfers int
 }
 
 func NewFakeClient(issues []*Issue, fails bool, message string) *FakeClient {
@@ -30,60 +51,258 @@ func NewFakeClient(issues []*Issue, fails bool, message string) *FakeClient {
 	}
 }
 
-func (f *FakeClient) FindIssue(ghIssueSpec examplev1alpha1.GitHubIssueSpec, token string) (*Issue, error) {
-	//check if there's an item in the repository issues list with the matching title
+func (f *FakeClient) FindIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, tokenSource TokenSource) (*Issue, error) {
+	if err := f.rateLimited(ctx, ghIssueSpec.Repo, tokenSource); err != nil {
+		return nil, err
+	}
+	//check if there's an item in the repository issues list with the matching title (and repo, once moved)
 	for _, issue := range f.Issues {
-		if ghIssueSpec.Title == issue.Title {
+		if ghIssueSpec.Title == issue.Title && strings.Contains(strings.ToLower(issue.Repo), strings.ToLower(ghIssueSpec.Repo)) {
 			return issue, nil
 		}
 	}
 
 	//if there's no item in the repository issues list with the matching title
-	return nil, fmt.Errorf(TitleNotFound)
+	return nil, ErrTitleNotFound
+}
+
+func (f *FakeClient) FindIssueByMarker(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, marker string,
+	since time.Time, tokenSource TokenSource) (*Issue, error) {
+	if err := f.rateLimited(ctx, ghIssueSpec.Repo, tokenSource); err != nil {
+		return nil, err
+	}
+	//the latest created issue of the repository with the marker in its body
+	for i := len(f.Issues) - 1; i >= 0; i-- {
+		issue := f.Issues[i]
+		if strings.Contains(issue.Description, marker) && strings.Contains(strings.ToLower(issue.Repo), strings.ToLower(ghIssueSpec.Repo)) {
+			return issue, nil
+		}
+	}
+	return nil, ErrMarkerNotFound
+}
+
+func (f *FakeClient) GetIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string, tokenSource TokenSource) (*Issue, error) {
+	if err := f.rateLimited(ctx, ghIssueSpec.Repo, tokenSource); err != nil {
+		return nil, err
+	}
+	if issue := f.getByNumber(issueNumber); issue != nil {
+		return issue, nil
+	}
+	return nil, &APIError{Method: "GET", URL: "issues/" + issueNumber, StatusCode: http.StatusNotFound,
+		Message: "Not Found"}
 }
 
-func (f *FakeClient) Create(ghIssueSpec examplev1alpha1.GitHubIssueSpec, token string) (*Issue, error) {
+func (f *FakeClient) Create(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, tokenSource TokenSource) (*Issue, error) {
+	if err := f.rateLimited(ctx, ghIssueSpec.Repo, tokenSource); err != nil {
+		return nil, err
+	}
 	if fmt.Sprintf("%v", f.Err) == CreatError {
 		return &Issue{}, f.Err
 	}
 	issue := Issue{
-		Repo:                "https://api.github.com/repos" + ghIssueSpec.Repo + "/issues",
+		Repo:                NormalizeBaseURL(ghIssueSpec.BaseURL) + "repos/" + ghIssueSpec.Repo + "/issues",
 		Title:               ghIssueSpec.Title,
 		Description:         ghIssueSpec.Description,
 		IssueNumber:         json.Number(strconv.Itoa(len(f.Issues) + 1)),
 		State:               "open",
 		LastUpdateTimestamp: "2021-05-31T07:49:28Z",//time.Now().String(), //"2021-05-31T07:49:28Z",
+		NodeID:              "I_fake" + strconv.Itoa(len(f.Issues)+1),
+		HTMLURL:             "https://github.com/" + ghIssueSpec.Repo + "/issues/" + strconv.Itoa(len(f.Issues)+1),
+		Author:              &User{Login: FakeLogin},
+		CreatedAt:           &fakeNow,
 	}
+	applySpec(&issue, ghIssueSpec)
 	f.Issues = append(f.Issues, &issue)
 	return &issue, nil
 }
 
-func (f *FakeClient) Edit(ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, token string) error {
+func (f *FakeClient) Edit(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string, tokenSource TokenSource) (*Issue, error) {
+	if err := f.rateLimited(ctx, ghIssueSpec.Repo, tokenSource); err != nil {
+		return nil, err
+	}
 	if fmt.Sprintf("%v", f.Err) == EditError {
-		return f.Err
+		return nil, f.Err
 	}
-	for _, issue := range f.Issues {
-		if issue.Title == ghIssueSpec.Title {
-			issue.Description = ghIssueSpec.Description
-			return nil
+	if issue := f.getByNumber(issueNumber); issue != nil {
+		issue.Title = ghIssueSpec.Title
+		issue.Description = ghIssueSpec.Description
+		applySpec(issue, ghIssueSpec)
+		wasClosed := issue.State == "closed"
+		issue.State = DesiredState(ghIssueSpec)
+		issue.StateReason = ""
+		if issue.State == "closed" {
+			issue.StateReason = string(ghIssueSpec.StateReason)
+			if !wasClosed {
+				issue.ClosedAt = &fakeNow
+			}
 		}
+		return issue, nil
 	}
-	return fmt.Errorf("couldn't find issue title in repo")
+	return nil, fmt.Errorf("couldn't find issue number in repo")
 }
 
-func (f *FakeClient) Close(ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, token string) error {
+func (f *FakeClient) Close(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string, tokenSource TokenSource) error {
+	if err := f.rateLimited(ctx, ghIssueSpec.Repo, tokenSource); err != nil {
+		return err
+	}
 	if fmt.Sprintf("%v", f.Err) == DeleteError {
 		return f.Err
 	}
+	if issue := f.getByNumber(issueNumber); issue != nil {
+		issue.State = "closed"
+		issue.StateReason = string(ghIssueSpec.StateReason)
+		issue.ClosedAt = &fakeNow
+		return nil
+	}
+	return fmt.Errorf("couldn't find issue number in repo")
+}
+
+// TransferIssue moves the issue to the target repo, where it gets the next number of the fake repository
+func (f *FakeClient) TransferIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, targetRepo string,
+	tokenSource TokenSource) (*Issue, error) {
+	if err := f.rateLimited(ctx, ghIssueSpec.Repo, tokenSource); err != nil {
+		return nil, err
+	}
+	issue := f.getByNumber(issueNumber)
+	if issue == nil {
+		return nil, &APIError{Method: "POST", URL: "graphql", StatusCode: http.StatusNotFound, Message: "Not Found"}
+	}
+	number := strconv.Itoa(len(f.Issues) + 1)
+	if f.Comments != nil {
+		f.Comments[number] = f.Comments[issueNumber]
+		delete(f.Comments, issueNumber)
+	}
+	issue.IssueNumber = json.Number(number)
+	issue.Repo = NormalizeBaseURL(ghIssueSpec.BaseURL) + "repos/" + targetRepo + "/issues"
+	issue.HTMLURL = "https://github.com/" + targetRepo + "/issues/" + number
+	f.Transfers++
+	return issue, nil
+}
+
+func (f *FakeClient) LocateIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, nodeID string,
+	tokenSource TokenSource) (string, string, error) {
+	if err := f.rateLimited(ctx, ghIssueSpec.Repo, tokenSource); err != nil {
+		return "", "", err
+	}
+	for _, issue := range f.Issues {
+		if issue.NodeID == nodeID {
+			// the fake's issues hold their repo, or the api url of their repo's issues
+			repo := issue.Repo
+			if i := strings.Index(repo, "repos/"); i >= 0 {
+				repo = strings.TrimSuffix(repo[i+len("repos/"):], "/issues")
+			}
+			return repo, string(issue.IssueNumber), nil
+		}
+	}
+	return "", "", &APIError{Method: "POST", URL: "graphql", StatusCode: http.StatusNotFound, Message: "Not Found"}
+}
+
+func (f *FakeClient) CreateComment(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, body string,
+	tokenSource TokenSource) (*Comment, error) {
+	if err := f.rateLimited(ctx, ghIssueSpec.Repo, tokenSource); err != nil {
+		return nil, err
+	}
+	issue := f.getByNumber(issueNumber)
+	if issue == nil {
+		return nil, fmt.Errorf("couldn't find issue number in repo")
+	}
+	issue.Comments++
+	if f.Comments == nil {
+		f.Comments = map[string][]*Comment{}
+	}
+	f.lastCommentID++
+	comment := &Comment{ID: f.lastCommentID, Body: body}
+	f.Comments[issueNumber] = append(f.Comments[issueNumber], comment)
+	return comment, nil
+}
+
+func (f *FakeClient) ListComments(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string,
+	tokenSource TokenSource) ([]*Comment, error) {
+	if err := f.rateLimited(ctx, ghIssueSpec.Repo, tokenSource); err != nil {
+		return nil, err
+	}
+	if f.getByNumber(issueNumber) == nil {
+		return nil, fmt.Errorf("couldn't find issue number in repo")
+	}
+	return f.Comments[issueNumber], nil
+}
+
+func (f *FakeClient) EditComment(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, edited *Comment, body string,
+	tokenSource TokenSource) (*Comment, error) {
+	if err := f.rateLimited(ctx, ghIssueSpec.Repo, tokenSource); err != nil {
+		return nil, err
+	}
+	for _, comments := range f.Comments {
+		for _, comment := range comments {
+			if comment.ID == edited.ID {
+				comment.Body = body
+				return comment, nil
+			}
+		}
+	}
+	return nil, &APIError{Method: "PATCH", URL: "issues/comments", StatusCode: http.StatusNotFound, Message: "Not Found"}
+}
+
+func (f *FakeClient) DeleteComment(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, deleted *Comment,
+	tokenSource TokenSource) error {
+	if err := f.rateLimited(ctx, ghIssueSpec.Repo, tokenSource); err != nil {
+		return err
+	}
+	for issueNumber, comments := range f.Comments {
+		for i, comment := range comments {
+			if comment.ID == deleted.ID {
+				f.Comments[issueNumber] = append(comments[:i], comments[i+1:]...)
+				if issue := f.getByNumber(issueNumber); issue != nil {
+					issue.Comments--
+				}
+				return nil
+			}
+		}
+	}
+	return &APIError{Method: "DELETE", URL: "issues/comments", StatusCode: http.StatusNotFound, Message: "Not Found"}
+}
+
+// applySpec sets the labels, assignees and milestone the spec manages on the issue, the way github does
+func applySpec(issue *Issue, ghIssueSpec examplev1alpha1.GitHubIssueSpec) {
+	if len(ghIssueSpec.Labels) > 0 {
+		issue.Labels = nil
+		for _, name := range ghIssueSpec.Labels {
+			issue.Labels = append(issue.Labels, Label{Name: name})
+		}
+	}
+	if len(ghIssueSpec.Assignees) > 0 {
+		issue.Assignees = nil
+		for _, login := range ghIssueSpec.Assignees {
+			issue.Assignees = append(issue.Assignees, User{Login: login})
+		}
+	}
+	if ghIssueSpec.Milestone != nil {
+		issue.Milestone = &Milestone{Number: *ghIssueSpec.Milestone}
+	}
+}
+
+// rateLimited records the token of the call and returns a *RateLimitError while RateLimitReset is in the future
+func (f *FakeClient) rateLimited(ctx context.Context, repo string, tokenSource TokenSource) error {
+	token, err := tokenSource.Token(ctx, repo)
+	if err != nil {
+		return err
+	}
+	f.LastToken = token
+	f.Repos = append(f.Repos, repo)
+	if time.Now().Before(f.RateLimitReset) {
+		return &RateLimitError{Reset: f.RateLimitReset}
+	}
+	return nil
+}
+
+// getByNumber returns the issue in the fake repository with the given number, or nil
+func (f *FakeClient) getByNumber(issueNumber string) *Issue {
 	for _, issue := range f.Issues {
-		if issue.Title == ghIssueSpec.Title {
-			issue.State = "closed"
-			//f.Issues[i] = f.Issues[len(f.Issues)-1]
-			//f.Issues = f.Issues[:len(f.Issues)-1]
-			return nil
+		if string(issue.IssueNumber) == issueNumber {
+			return issue
 		}
 	}
-	return fmt.Errorf("couldn't find issue title in repo")
+	return nil
 }
 
 //func (f *FakeClient) Fail(message string) {
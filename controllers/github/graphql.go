@@ -0,0 +1,213 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+)
+
+// graphQLRequest is the body of a request to github's graphql api
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// graphQLResponse is the body of a response of github's graphql api
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphQLError  `json:"errors"`
+}
+
+// graphQLError is an error of a graphql response, path leads to the field it nulled in the data
+type graphQLError struct {
+	Type    string        `json:"type"`
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path"`
+}
+
+// optional : whether the error only cost a field the operator can do without. project items need the
+// read:project scope, which many tokens don't have, the issue still comes with the rest of its fields
+func (e graphQLError) optional() bool {
+	if e.Type != "INSUFFICIENT_SCOPES" && e.Type != "FORBIDDEN" {
+		return false
+	}
+	for _, field := range e.Path {
+		if field == "projectItems" {
+			return true
+		}
+	}
+	return false
+}
+
+// GraphQLURL returns the graphql endpoint next to a rest api base url: https://api.github.com/graphql for
+// github.com and https://<host>/api/graphql for github enterprise server
+func GraphQLURL(baseURL string) string {
+	baseURL = NormalizeBaseURL(baseURL)
+	if strings.HasSuffix(baseURL, "/api/v3/") {
+		return strings.TrimSuffix(baseURL, "v3/") + "graphql"
+	}
+	return baseURL + "graphql"
+}
+
+// graphQL : send a graphql query (or mutation) to the api root of the spec, or of the client, and decode its data
+// into result. graphql errors are returned even though github answers them with 200, except the ones only
+// missing an optional field
+func (c *ClientAPI) graphQL(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, token, query string,
+	variables map[string]interface{}, result interface{}) error {
+	baseURL := ghIssueSpec.BaseURL
+	if baseURL == "" {
+		baseURL = c.BaseURL
+	}
+	// a query changes nothing, unlike a mutation it's safe to send again when it fails
+	if strings.HasPrefix(query, "query") {
+		ctx = withIdempotent(ctx)
+	}
+	var resp graphQLResponse
+	if _, err := c.do(ctx, "POST", GraphQLURL(baseURL), token, graphQLRequest{Query: query, Variables: variables}, &resp); err != nil {
+		return err
+	}
+	var errs []graphQLError
+	for _, graphQLErr := range resp.Errors {
+		if !graphQLErr.optional() {
+			errs = append(errs, graphQLErr)
+		}
+	}
+	if len(errs) > 0 {
+		var messages []string
+		for _, graphQLErr := range errs {
+			messages = append(messages, graphQLErr.Message)
+		}
+		err := fmt.Errorf("graphql: %s", strings.Join(messages, "; "))
+		switch errs[0].Type {
+		case "NOT_FOUND":
+			return fmt.Errorf("%v: %w", err, ErrNotFound)
+		case "FORBIDDEN", "INSUFFICIENT_SCOPES":
+			return fmt.Errorf("%v: %w", err, ErrForbidden)
+		case "RATE_LIMITED":
+			return fmt.Errorf("%v: %w", err, ErrRateLimited)
+		}
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Data, result)
+}
+
+const transferIDsQuery = `query($owner: String!, $name: String!, $number: Int!, $targetOwner: String!, $targetName: String!) {
+  repository(owner: $owner, name: $name) { issue(number: $number) { id } }
+  target: repository(owner: $targetOwner, name: $targetName) { id }
+}`
+
+const transferIssueMutation = `mutation($issueId: ID!, $repositoryId: ID!) {
+  transferIssue(input: {issueId: $issueId, repositoryId: $repositoryId}) { issue { number } }
+}`
+
+// TransferIssue : move the issue of the spec's repo to the target repo with graphql's transferIssue, github
+// only transfers within the same owner. the issue is returned as it is in the target repo (with its new number)
+func (c *ClientAPI) TransferIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, targetRepo string,
+	tokenSource TokenSource) (*Issue, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	number, err := c.transferIssue(ctx, ghIssueSpec, token, issueNumber, targetRepo)
+	if err != nil {
+		return nil, err
+	}
+	targetSpec := ghIssueSpec
+	targetSpec.Repo = targetRepo
+	return c.GetIssue(ctx, targetSpec, number, tokenSource)
+}
+
+// transferIssue : look up the ids transferIssue needs and send it, returning the number of the issue in the
+// target repo
+func (c *ClientAPI) transferIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, token, issueNumber,
+	targetRepo string) (string, error) {
+	owner, name, err := splitRepo(ghIssueSpec.Repo)
+	if err != nil {
+		return "", err
+	}
+	targetOwner, targetName, err := splitRepo(targetRepo)
+	if err != nil {
+		return "", err
+	}
+	number, err := json.Number(issueNumber).Int64()
+	if err != nil {
+		return "", fmt.Errorf("issue number %q: %w", issueNumber, err)
+	}
+
+	var ids struct {
+		Repository struct {
+			Issue *struct {
+				ID string `json:"id"`
+			} `json:"issue"`
+		} `json:"repository"`
+		Target struct {
+			ID string `json:"id"`
+		} `json:"target"`
+	}
+	if err = c.graphQL(ctx, ghIssueSpec, token, transferIDsQuery, map[string]interface{}{
+		"owner": owner, "name": name, "number": number, "targetOwner": targetOwner, "targetName": targetName,
+	}, &ids); err != nil {
+		return "", err
+	}
+	if ids.Repository.Issue == nil {
+		return "", fmt.Errorf("issue %s#%s: %w", ghIssueSpec.Repo, issueNumber, ErrNotFound)
+	}
+
+	var transferred struct {
+		TransferIssue struct {
+			Issue struct {
+				Number int `json:"number"`
+			} `json:"issue"`
+		} `json:"transferIssue"`
+	}
+	if err = c.graphQL(ctx, ghIssueSpec, token, transferIssueMutation, map[string]interface{}{
+		"issueId": ids.Repository.Issue.ID, "repositoryId": ids.Target.ID,
+	}, &transferred); err != nil {
+		return "", err
+	}
+	return fmt.Sprint(transferred.TransferIssue.Issue.Number), nil
+}
+
+const locateIssueQuery = `query($id: ID!) {
+  node(id: $id) { ... on Issue { number repository { nameWithOwner } } }
+}`
+
+// LocateIssue : the repo and the number of the issue with the node id. node ids survive transfers, unlike
+// numbers, so this finds an issue moved by an earlier attempt. the rest api has no such lookup, graphql is used
+func (c *ClientAPI) LocateIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, nodeID string,
+	tokenSource TokenSource) (string, string, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return "", "", err
+	}
+	var located struct {
+		Node *struct {
+			Number     int `json:"number"`
+			Repository struct {
+				NameWithOwner string `json:"nameWithOwner"`
+			} `json:"repository"`
+		} `json:"node"`
+	}
+	if err = c.graphQL(ctx, ghIssueSpec, token, locateIssueQuery, map[string]interface{}{"id": nodeID}, &located); err != nil {
+		return "", "", err
+	}
+	if located.Node == nil || located.Node.Number == 0 {
+		return "", "", fmt.Errorf("issue %s: %w", nodeID, ErrNotFound)
+	}
+	return located.Node.Repository.NameWithOwner, fmt.Sprint(located.Node.Number), nil
+}
+
+// splitRepo : the owner and the name of an "owner/name" repo
+func splitRepo(repo string) (string, string, error) {
+	parts := strings.Split(repo, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("repo %q isn't of the form owner/name", repo)
+	}
+	return parts[0], parts[1], nil
+}
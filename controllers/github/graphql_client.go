@@ -0,0 +1,616 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+)
+
+// GraphQLClient implements Client with github's graphql api (v4). an issue is fetched with its labels,
+// assignees, comments and project items in a single request, and every change is a single mutation after
+// looking up the ids it needs
+type GraphQLClient struct {
+	// API sends the requests, its base url, rate limit tracker and metrics apply to the graphql requests too
+	API *ClientAPI
+}
+
+var _ Client = &GraphQLClient{}
+
+// issueFieldsFragment selects the fields of Issue out of a graphql issue
+const issueFieldsFragment = `fragment issueFields on Issue {
+  id number title body state stateReason url createdAt updatedAt closedAt
+  author { login }
+  labels(first: 100) { nodes { name } }
+  assignees(first: 100) { nodes { login } }
+  milestone { number title }
+  comments(first: 100) { totalCount nodes { id databaseId body } }
+  projectItems(first: 100) { nodes { id project { number title } } }
+}`
+
+const getIssueQuery = `query($owner: String!, $name: String!, $number: Int!) {
+  repository(owner: $owner, name: $name) { issue(number: $number) { ...issueFields } }
+}
+` + issueFieldsFragment
+
+const findIssueQuery = `query($owner: String!, $name: String!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    issues(first: 100, after: $cursor) { pageInfo { hasNextPage endCursor } nodes { number title } }
+  }
+}`
+
+const findIssueByMarkerQuery = `query($owner: String!, $name: String!, $since: DateTime, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    issues(first: 100, after: $cursor, filterBy: {since: $since}, orderBy: {field: CREATED_AT, direction: DESC}) {
+      pageInfo { hasNextPage endCursor } nodes { number body }
+    }
+  }
+}`
+
+const listCommentsQuery = `query($owner: String!, $name: String!, $number: Int!, $cursor: String) {
+  repository(owner: $owner, name: $name) {
+    issue(number: $number) {
+      comments(first: 100, after: $cursor) { pageInfo { hasNextPage endCursor } nodes { id databaseId body } }
+    }
+  }
+}`
+
+const addCommentMutation = `mutation($input: AddCommentInput!) {
+  addComment(input: $input) { commentEdge { node { id databaseId body } } }
+}`
+
+const updateCommentMutation = `mutation($input: UpdateIssueCommentInput!) {
+  updateIssueComment(input: $input) { issueComment { id databaseId body } }
+}`
+
+const deleteCommentMutation = `mutation($input: DeleteIssueCommentInput!) {
+  deleteIssueComment(input: $input) { clientMutationId }
+}`
+
+// graphQLIssue is an issue as selected by issueFieldsFragment
+type graphQLIssue struct {
+	ID          string     `json:"id"`
+	Number      int        `json:"number"`
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	State       string     `json:"state"`
+	StateReason string     `json:"stateReason"`
+	URL         string     `json:"url"`
+	CreatedAt   *time.Time `json:"createdAt"`
+	UpdatedAt   string     `json:"updatedAt"`
+	ClosedAt    *time.Time `json:"closedAt"`
+	Author      *User      `json:"author"`
+	Labels      struct {
+		Nodes []Label `json:"nodes"`
+	} `json:"labels"`
+	Assignees struct {
+		Nodes []User `json:"nodes"`
+	} `json:"assignees"`
+	Milestone *Milestone `json:"milestone"`
+	Comments  struct {
+		TotalCount int              `json:"totalCount"`
+		Nodes      []graphQLComment `json:"nodes"`
+	} `json:"comments"`
+	ProjectItems struct {
+		Nodes []struct {
+			ID      string `json:"id"`
+			Project struct {
+				Number int    `json:"number"`
+				Title  string `json:"title"`
+			} `json:"project"`
+		} `json:"nodes"`
+	} `json:"projectItems"`
+}
+
+// graphQLComment is an issue comment as selected by the queries above
+type graphQLComment struct {
+	ID         string `json:"id"`
+	DatabaseID int64  `json:"databaseId"`
+	Body       string `json:"body"`
+}
+
+// pageInfo is graphql's cursor pagination of a connection
+type pageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// toComment : the comment as the rest api returns it
+func (c graphQLComment) toComment() *Comment {
+	return &Comment{ID: c.DatabaseID, NodeID: c.ID, Body: c.Body}
+}
+
+// toIssue : the issue as the rest api returns it, issuesURL is the api url of the issues of its repo
+func (i *graphQLIssue) toIssue(issuesURL string) *Issue {
+	number := fmt.Sprint(i.Number)
+	issue := &Issue{
+		Repo:                issuesURL + "/" + number,
+		HTMLURL:             i.URL,
+		Title:               i.Title,
+		Description:         i.Body,
+		IssueNumber:         json.Number(number),
+		State:               strings.ToLower(i.State),
+		StateReason:         strings.ToLower(i.StateReason),
+		LastUpdateTimestamp: i.UpdatedAt,
+		NodeID:              i.ID,
+		Labels:              i.Labels.Nodes,
+		Assignees:           i.Assignees.Nodes,
+		Milestone:           i.Milestone,
+		Author:              i.Author,
+		Comments:            i.Comments.TotalCount,
+		CreatedAt:           i.CreatedAt,
+		ClosedAt:            i.ClosedAt,
+		FetchedComments:     []*Comment{},
+	}
+	for _, comment := range i.Comments.Nodes {
+		issue.FetchedComments = append(issue.FetchedComments, comment.toComment())
+	}
+	for _, item := range i.ProjectItems.Nodes {
+		issue.ProjectItems = append(issue.ProjectItems, ProjectItem{ID: item.ID, ProjectNumber: item.Project.Number,
+			ProjectTitle: item.Project.Title})
+	}
+	return issue
+}
+
+// repoVariables : the owner and name variables of the spec's repo, with the issue number when it isn't empty
+func repoVariables(ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string) (map[string]interface{}, error) {
+	owner, name, err := splitRepo(ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	variables := map[string]interface{}{"owner": owner, "name": name}
+	if issueNumber != "" {
+		number, err := json.Number(issueNumber).Int64()
+		if err != nil {
+			return nil, fmt.Errorf("issue number %q: %w", issueNumber, err)
+		}
+		variables["number"] = number
+	}
+	return variables, nil
+}
+
+// FindIssue : look for the issue with the title of the spec over all the repository issues, and fetch it
+func (g *GraphQLClient) FindIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, tokenSource TokenSource) (*Issue, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := repoVariables(ghIssueSpec, "")
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var page struct {
+			Repository struct {
+				Issues struct {
+					PageInfo pageInfo `json:"pageInfo"`
+					Nodes    []struct {
+						Number int    `json:"number"`
+						Title  string `json:"title"`
+					} `json:"nodes"`
+				} `json:"issues"`
+			} `json:"repository"`
+		}
+		if err = g.API.graphQL(ctx, ghIssueSpec, token, findIssueQuery, variables, &page); err != nil {
+			return nil, err
+		}
+		for _, issue := range page.Repository.Issues.Nodes {
+			if issue.Title == ghIssueSpec.Title {
+				return g.getIssue(ctx, ghIssueSpec, token, fmt.Sprint(issue.Number))
+			}
+		}
+		if !page.Repository.Issues.PageInfo.HasNextPage {
+			return nil, ErrTitleNotFound
+		}
+		variables["cursor"] = page.Repository.Issues.PageInfo.EndCursor
+	}
+}
+
+// FindIssueByMarker : go over the issues updated since the given time, newest first, for the marker
+func (g *GraphQLClient) FindIssueByMarker(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, marker string,
+	since time.Time, tokenSource TokenSource) (*Issue, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := repoVariables(ghIssueSpec, "")
+	if err != nil {
+		return nil, err
+	}
+	if !since.IsZero() {
+		variables["since"] = since.UTC().Format(time.RFC3339)
+	}
+	for {
+		var page struct {
+			Repository struct {
+				Issues struct {
+					PageInfo pageInfo `json:"pageInfo"`
+					Nodes    []struct {
+						Number int    `json:"number"`
+						Body   string `json:"body"`
+					} `json:"nodes"`
+				} `json:"issues"`
+			} `json:"repository"`
+		}
+		if err = g.API.graphQL(ctx, ghIssueSpec, token, findIssueByMarkerQuery, variables, &page); err != nil {
+			return nil, err
+		}
+		for _, issue := range page.Repository.Issues.Nodes {
+			if strings.Contains(issue.Body, marker) {
+				return g.getIssue(ctx, ghIssueSpec, token, fmt.Sprint(issue.Number))
+			}
+		}
+		if !page.Repository.Issues.PageInfo.HasNextPage {
+			return nil, ErrMarkerNotFound
+		}
+		variables["cursor"] = page.Repository.Issues.PageInfo.EndCursor
+	}
+}
+
+// GetIssue : fetch a github issue by its number, with its labels, assignees, comments and project items
+func (g *GraphQLClient) GetIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string,
+	tokenSource TokenSource) (*Issue, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	return g.getIssue(ctx, ghIssueSpec, token, issueNumber)
+}
+
+func (g *GraphQLClient) getIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, token, issueNumber string) (*Issue, error) {
+	variables, err := repoVariables(ghIssueSpec, issueNumber)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		Repository struct {
+			Issue *graphQLIssue `json:"issue"`
+		} `json:"repository"`
+	}
+	if err = g.API.graphQL(ctx, ghIssueSpec, token, getIssueQuery, variables, &result); err != nil {
+		return nil, err
+	}
+	if result.Repository.Issue == nil {
+		return nil, fmt.Errorf("issue %s#%s: %w", ghIssueSpec.Repo, issueNumber, ErrNotFound)
+	}
+	return result.Repository.Issue.toIssue(g.API.reposURL(ghIssueSpec) + "/issues"), nil
+}
+
+// Create : open an issue with the managed fields of the spec
+func (g *GraphQLClient) Create(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, tokenSource TokenSource) (*Issue, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := g.lookupIDs(ctx, ghIssueSpec, token, "")
+	if err != nil {
+		return nil, err
+	}
+	input := ids.issueInput(ghIssueSpec)
+	input["repositoryId"] = ids.repositoryID
+	return g.mutateIssue(ctx, ghIssueSpec, token, "createIssue", "CreateIssueInput", input, nil)
+}
+
+// Edit : set the managed fields of the spec (including its state) on the github issue, and return the issue as
+// github saved it. the fields and the state are changed by one request with the mutations one after another
+func (g *GraphQLClient) Edit(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string,
+	tokenSource TokenSource) (*Issue, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := g.lookupIDs(ctx, ghIssueSpec, token, issueNumber)
+	if err != nil {
+		return nil, err
+	}
+	input := ids.issueInput(ghIssueSpec)
+	input["id"] = ids.issueID
+
+	var stateChange *stateMutation
+	if DesiredState(ghIssueSpec) == string(examplev1alpha1.IssueStateClosed) {
+		stateReason := strings.ToUpper(string(ghIssueSpec.StateReason))
+		if ids.issueState != "CLOSED" || (stateReason != "" && ids.issueStateReason != stateReason) {
+			closeInput := map[string]interface{}{"issueId": ids.issueID}
+			if stateReason != "" {
+				closeInput["stateReason"] = stateReason
+			}
+			stateChange = &stateMutation{"closeIssue", "CloseIssueInput", closeInput}
+		}
+	} else if ids.issueState == "CLOSED" {
+		stateChange = &stateMutation{"reopenIssue", "ReopenIssueInput", map[string]interface{}{"issueId": ids.issueID}}
+	}
+	return g.mutateIssue(ctx, ghIssueSpec, token, "updateIssue", "UpdateIssueInput", input, stateChange)
+}
+
+// Close : close github issue
+func (g *GraphQLClient) Close(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string, tokenSource TokenSource) error {
+	closedSpec := ghIssueSpec
+	closedSpec.State = examplev1alpha1.IssueStateClosed
+	_, err := g.Edit(ctx, closedSpec, issueNumber, tokenSource)
+	return err
+}
+
+// TransferIssue : move the issue of the spec's repo to the target repo, github only transfers within the same
+// owner. the issue is returned as it is in the target repo (with its new number)
+func (g *GraphQLClient) TransferIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, targetRepo string,
+	tokenSource TokenSource) (*Issue, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	number, err := g.API.transferIssue(ctx, ghIssueSpec, token, issueNumber, targetRepo)
+	if err != nil {
+		return nil, err
+	}
+	targetSpec := ghIssueSpec
+	targetSpec.Repo = targetRepo
+	return g.getIssue(ctx, targetSpec, token, number)
+}
+
+// LocateIssue : the repo and the number of the issue with the node id
+func (g *GraphQLClient) LocateIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, nodeID string,
+	tokenSource TokenSource) (string, string, error) {
+	return g.API.LocateIssue(ctx, ghIssueSpec, nodeID, tokenSource)
+}
+
+// ListComments : all the comments of a github issue, following graphql's pagination
+func (g *GraphQLClient) ListComments(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber string,
+	tokenSource TokenSource) ([]*Comment, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := repoVariables(ghIssueSpec, issueNumber)
+	if err != nil {
+		return nil, err
+	}
+	var comments []*Comment
+	for {
+		var page struct {
+			Repository struct {
+				Issue *struct {
+					Comments struct {
+						PageInfo pageInfo         `json:"pageInfo"`
+						Nodes    []graphQLComment `json:"nodes"`
+					} `json:"comments"`
+				} `json:"issue"`
+			} `json:"repository"`
+		}
+		if err = g.API.graphQL(ctx, ghIssueSpec, token, listCommentsQuery, variables, &page); err != nil {
+			return nil, err
+		}
+		if page.Repository.Issue == nil {
+			return nil, fmt.Errorf("issue %s#%s: %w", ghIssueSpec.Repo, issueNumber, ErrNotFound)
+		}
+		for _, comment := range page.Repository.Issue.Comments.Nodes {
+			comments = append(comments, comment.toComment())
+		}
+		if !page.Repository.Issue.Comments.PageInfo.HasNextPage {
+			return comments, nil
+		}
+		variables["cursor"] = page.Repository.Issue.Comments.PageInfo.EndCursor
+	}
+}
+
+// CreateComment : post a comment on github issue
+func (g *GraphQLClient) CreateComment(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, issueNumber, body string,
+	tokenSource TokenSource) (*Comment, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := g.lookupIDs(ctx, examplev1alpha1.GitHubIssueSpec{Repo: ghIssueSpec.Repo, BaseURL: ghIssueSpec.BaseURL},
+		token, issueNumber)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		AddComment struct {
+			CommentEdge struct {
+				Node graphQLComment `json:"node"`
+			} `json:"commentEdge"`
+		} `json:"addComment"`
+	}
+	if err = g.API.graphQL(ctx, ghIssueSpec, token, addCommentMutation, map[string]interface{}{
+		"input": map[string]interface{}{"subjectId": ids.issueID, "body": body},
+	}, &result); err != nil {
+		return nil, err
+	}
+	return result.AddComment.CommentEdge.Node.toComment(), nil
+}
+
+// EditComment : replace the body of a comment
+func (g *GraphQLClient) EditComment(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, comment *Comment, body string,
+	tokenSource TokenSource) (*Comment, error) {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return nil, err
+	}
+	var result struct {
+		UpdateIssueComment struct {
+			IssueComment graphQLComment `json:"issueComment"`
+		} `json:"updateIssueComment"`
+	}
+	if err = g.API.graphQL(ctx, ghIssueSpec, token, updateCommentMutation, map[string]interface{}{
+		"input": map[string]interface{}{"id": comment.NodeID, "body": body},
+	}, &result); err != nil {
+		return nil, err
+	}
+	return result.UpdateIssueComment.IssueComment.toComment(), nil
+}
+
+// DeleteComment : delete a comment
+func (g *GraphQLClient) DeleteComment(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, comment *Comment,
+	tokenSource TokenSource) error {
+	token, err := tokenSource.Token(ctx, ghIssueSpec.Repo)
+	if err != nil {
+		return err
+	}
+	return g.API.graphQL(ctx, ghIssueSpec, token, deleteCommentMutation, map[string]interface{}{
+		"input": map[string]interface{}{"id": comment.NodeID},
+	}, nil)
+}
+
+// stateMutation is a closeIssue or reopenIssue sent after updateIssue in the same request
+type stateMutation struct {
+	field     string
+	inputType string
+	input     map[string]interface{}
+}
+
+// mutateIssue : send the issue mutation, followed by the state mutation if any, and return the issue as the last
+// of them left it
+func (g *GraphQLClient) mutateIssue(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, token, field, inputType string,
+	input map[string]interface{}, stateChange *stateMutation) (*Issue, error) {
+	declarations := "$input: " + inputType + "!"
+	selections := field + "(input: $input) { issue { ...issueFields } }"
+	variables := map[string]interface{}{"input": input}
+	last := field
+	if stateChange != nil {
+		declarations += ", $stateInput: " + stateChange.inputType + "!"
+		selections += "\n  " + stateChange.field + "(input: $stateInput) { issue { ...issueFields } }"
+		variables["stateInput"] = stateChange.input
+		last = stateChange.field
+	}
+	mutation := fmt.Sprintf("mutation(%s) {\n  %s\n}\n%s", declarations, selections, issueFieldsFragment)
+
+	var result map[string]struct {
+		Issue *graphQLIssue `json:"issue"`
+	}
+	if err := g.API.graphQL(ctx, ghIssueSpec, token, mutation, variables, &result); err != nil {
+		return nil, err
+	}
+	if result[last].Issue == nil {
+		return nil, fmt.Errorf("graphql: %s returned no issue", last)
+	}
+	return result[last].Issue.toIssue(g.API.reposURL(ghIssueSpec) + "/issues"), nil
+}
+
+// issueIDs are the node ids a mutation of the spec's issue needs
+type issueIDs struct {
+	repositoryID     string
+	issueID          string
+	issueState       string
+	issueStateReason string
+	labelIDs         []string
+	assigneeIDs      []string
+	milestoneID      string
+}
+
+// issueInput : the managed fields of the spec as the input of createIssue and updateIssue. like with the rest
+// api, labels, assignees and milestone are left as they are when the spec doesn't set them
+func (ids *issueIDs) issueInput(ghIssueSpec examplev1alpha1.GitHubIssueSpec) map[string]interface{} {
+	input := map[string]interface{}{"title": ghIssueSpec.Title, "body": ghIssueSpec.Description}
+	if len(ghIssueSpec.Labels) > 0 {
+		input["labelIds"] = ids.labelIDs
+	}
+	if len(ghIssueSpec.Assignees) > 0 {
+		input["assigneeIds"] = ids.assigneeIDs
+	}
+	if ghIssueSpec.Milestone != nil {
+		input["milestoneId"] = ids.milestoneID
+	}
+	return input
+}
+
+// lookupIDs : look up in one query the ids of the repo, of the issue (when issueNumber isn't empty) and of the
+// labels, assignees and milestone of the spec. names github doesn't know are returned as ErrValidation, as the
+// rest api would
+func (g *GraphQLClient) lookupIDs(ctx context.Context, ghIssueSpec examplev1alpha1.GitHubIssueSpec, token, issueNumber string) (*issueIDs, error) {
+	variables, err := repoVariables(ghIssueSpec, issueNumber)
+	if err != nil {
+		return nil, err
+	}
+	declarations := []string{"$owner: String!", "$name: String!"}
+	repoSelections := []string{"id"}
+	var userSelections []string
+	if issueNumber != "" {
+		declarations = append(declarations, "$number: Int!")
+		repoSelections = append(repoSelections, "issue(number: $number) { id state stateReason }")
+	}
+	for i, label := range ghIssueSpec.Labels {
+		alias := fmt.Sprintf("l%d", i)
+		declarations = append(declarations, "$"+alias+": String!")
+		repoSelections = append(repoSelections, fmt.Sprintf("%s: label(name: $%s) { id }", alias, alias))
+		variables[alias] = label
+	}
+	for i, login := range ghIssueSpec.Assignees {
+		alias := fmt.Sprintf("a%d", i)
+		declarations = append(declarations, "$"+alias+": String!")
+		userSelections = append(userSelections, fmt.Sprintf("%s: user(login: $%s) { id }", alias, alias))
+		variables[alias] = login
+	}
+	if ghIssueSpec.Milestone != nil {
+		declarations = append(declarations, "$milestone: Int!")
+		repoSelections = append(repoSelections, "milestone(number: $milestone) { id }")
+		variables["milestone"] = *ghIssueSpec.Milestone
+	}
+	query := fmt.Sprintf("query(%s) {\n  repository(owner: $owner, name: $name) { %s }\n  %s\n}",
+		strings.Join(declarations, ", "), strings.Join(repoSelections, " "), strings.Join(userSelections, "\n  "))
+
+	// the users are top level fields next to the repository
+	var data map[string]json.RawMessage
+	if err = g.API.graphQL(ctx, ghIssueSpec, token, query, variables, &data); err != nil {
+		return nil, err
+	}
+	var repository map[string]json.RawMessage
+	if err = json.Unmarshal(data["repository"], &repository); err != nil {
+		return nil, err
+	}
+	if repository == nil {
+		return nil, fmt.Errorf("repo %s: %w", ghIssueSpec.Repo, ErrNotFound)
+	}
+
+	ids := &issueIDs{}
+	if err = json.Unmarshal(repository["id"], &ids.repositoryID); err != nil {
+		return nil, err
+	}
+	if issueNumber != "" {
+		issue := lookupNode(repository["issue"])
+		if issue == nil {
+			return nil, fmt.Errorf("issue %s#%s: %w", ghIssueSpec.Repo, issueNumber, ErrNotFound)
+		}
+		ids.issueID, ids.issueState, ids.issueStateReason = issue.ID, issue.State, issue.StateReason
+	}
+	for i, label := range ghIssueSpec.Labels {
+		found := lookupNode(repository[fmt.Sprintf("l%d", i)])
+		if found == nil {
+			return nil, fmt.Errorf("label %q of %s: %w", label, ghIssueSpec.Repo, ErrValidation)
+		}
+		ids.labelIDs = append(ids.labelIDs, found.ID)
+	}
+	for i, login := range ghIssueSpec.Assignees {
+		found := lookupNode(data[fmt.Sprintf("a%d", i)])
+		if found == nil {
+			return nil, fmt.Errorf("assignee %q: %w", login, ErrValidation)
+		}
+		ids.assigneeIDs = append(ids.assigneeIDs, found.ID)
+	}
+	if ghIssueSpec.Milestone != nil {
+		found := lookupNode(repository["milestone"])
+		if found == nil {
+			return nil, fmt.Errorf("milestone %d of %s: %w", *ghIssueSpec.Milestone, ghIssueSpec.Repo, ErrValidation)
+		}
+		ids.milestoneID = found.ID
+	}
+	return ids, nil
+}
+
+// lookedUpNode is an object looked up by lookupIDs
+type lookedUpNode struct {
+	ID          string `json:"id"`
+	State       string `json:"state"`
+	StateReason string `json:"stateReason"`
+}
+
+// lookupNode : the object of a field of the lookup, nil when github didn't find it
+func lookupNode(raw json.RawMessage) *lookedUpNode {
+	var found *lookedUpNode
+	if err := json.Unmarshal(raw, &found); err != nil {
+		return nil
+	}
+	return found
+}
@@ -0,0 +1,352 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+)
+
+// graphQLAnswer is the data returned for the queries containing match
+type graphQLAnswer struct {
+	match string
+	data  string
+}
+
+// newGraphQLStub serves graphql requests with the data of the first unused answer matching the query, the last
+// matching answer is used again once the others are used up. it records the requests it gets
+func newGraphQLStub(t *testing.T, requests *[]graphQLRequest, answers ...graphQLAnswer) *httptest.Server {
+	used := make([]bool, len(answers))
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/graphql" {
+			t.Errorf("Expected a graphql request but got: %s %s", r.Method, r.URL.Path)
+		}
+		var request graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("Expected a graphql body but got an error: %v", err)
+		}
+		*requests = append(*requests, request)
+		matched := -1
+		for i, answer := range answers {
+			if strings.Contains(request.Query, answer.match) {
+				matched = i
+				if !used[i] {
+					break
+				}
+			}
+		}
+		if matched >= 0 {
+			used[matched] = true
+			_, _ = w.Write([]byte(`{"data":` + answers[matched].data + `}`))
+			return
+		}
+		t.Errorf("Unexpected query: %s", request.Query)
+		_, _ = w.Write([]byte(`{"errors":[{"message":"unexpected query"}]}`))
+	}))
+}
+
+// graphQLIssueData is issue #7 as selected by issueFieldsFragment, in the given state
+func graphQLIssueData(state string) string {
+	return `{"id":"I_7","number":7,"title":"testIssue","body":"testing...","state":"` + state + `",` +
+		`"stateReason":null,"url":"https://github.com/testUser/testRepo/issues/7",` +
+		`"createdAt":"2021-05-30T10:00:00Z","updatedAt":"2021-05-31T07:49:28Z","closedAt":null,` +
+		`"author":{"login":"octocat"},"labels":{"nodes":[{"name":"bug"}]},"assignees":{"nodes":[{"login":"octocat"}]},` +
+		`"milestone":{"number":2,"title":"v1"},` +
+		`"comments":{"totalCount":2,"nodes":[{"id":"IC_1","databaseId":1,"body":"first"},{"id":"IC_2","databaseId":2,"body":"second"}]},` +
+		`"projectItems":{"nodes":[{"id":"PVTI_1","project":{"number":3,"title":"Roadmap"}}]}}`
+}
+
+func newTestGraphQLClient(server *httptest.Server) *GraphQLClient {
+	return &GraphQLClient{API: newTestClientAPI(server)}
+}
+
+func TestGraphQLGetIssueInOneRequest(t *testing.T) {
+	//given a github serving issue #7
+	var requests []graphQLRequest
+	server := newGraphQLStub(t, &requests, graphQLAnswer{"issue(number: $number) { ...issueFields }",
+		`{"repository":{"issue":` + graphQLIssueData("OPEN") + `}}`})
+	defer server.Close()
+	g := newTestGraphQLClient(server)
+
+	//when fetching the issue
+	issue, err := g.GetIssue(context.Background(), examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}, "7", StaticToken("token"))
+
+	//then its labels, assignees, comments and project items come with it in a single request
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if len(requests) != 1 || requests[0].Variables["owner"] != "testUser" || requests[0].Variables["number"] != float64(7) {
+		t.Errorf("Expected one request for issue 7 but got: %v", requests)
+	}
+	if issue.IssueNumber != "7" || issue.State != "open" || issue.NodeID != "I_7" || issue.AuthorLogin() != "octocat" {
+		t.Errorf("Expected issue 7 but got: %+v", issue)
+	}
+	if fmt.Sprint(issue.LabelNames()) != "[bug]" || fmt.Sprint(issue.AssigneeLogins()) != "[octocat]" ||
+		issue.MilestoneNumber() != 2 {
+		t.Errorf("Expected labels, assignees and milestone but got: %+v", issue)
+	}
+	if issue.Comments != 2 || len(issue.FetchedComments) != 2 || issue.FetchedComments[1].ID != 2 ||
+		issue.FetchedComments[1].NodeID != "IC_2" {
+		t.Errorf("Expected both comments but got: %d, %v", issue.Comments, issue.FetchedComments)
+	}
+	if len(issue.ProjectItems) != 1 || issue.ProjectItems[0] != (ProjectItem{ID: "PVTI_1", ProjectNumber: 3, ProjectTitle: "Roadmap"}) {
+		t.Errorf("Expected the project item but got: %v", issue.ProjectItems)
+	}
+	if issue.Repo != server.URL+"/repos/testUser/testRepo/issues/7" || issue.CreatedAt == nil || issue.ClosedAt != nil {
+		t.Errorf("Expected the api url and timestamps but got: %s, %v, %v", issue.Repo, issue.CreatedAt, issue.ClosedAt)
+	}
+}
+
+func TestGraphQLGetMissingIssue(t *testing.T) {
+	//given a github without issue #7
+	var requests []graphQLRequest
+	server := newGraphQLStub(t, &requests, graphQLAnswer{"issue(number: $number)", `{"repository":{"issue":null}}`})
+	defer server.Close()
+	g := newTestGraphQLClient(server)
+
+	//when fetching the issue
+	_, err := g.GetIssue(context.Background(), examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}, "7", StaticToken("token"))
+
+	//then it's not found
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound but got: %v", err)
+	}
+}
+
+func TestGraphQLGetIssueWithoutProjectScope(t *testing.T) {
+	//given a token without the read:project scope, github nulls the project items of issue #7
+	issueData := strings.Replace(graphQLIssueData("OPEN"), `{"nodes":[{"id":"PVTI_1","project":{"number":3,"title":"Roadmap"}}]}`,
+		"null", 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"repository":{"issue":` + issueData + `}},"errors":[{"type":"INSUFFICIENT_SCOPES",` +
+			`"path":["repository","issue","projectItems"],"message":"Your token has not been granted the required scopes"}]}`))
+	}))
+	defer server.Close()
+	g := newTestGraphQLClient(server)
+
+	//when fetching the issue
+	issue, err := g.GetIssue(context.Background(), examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}, "7", StaticToken("token"))
+
+	//then the issue comes without its project items
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if issue.IssueNumber != "7" || fmt.Sprint(issue.LabelNames()) != "[bug]" || len(issue.ProjectItems) != 0 {
+		t.Errorf("Expected issue 7 without project items but got: %+v", issue)
+	}
+}
+
+func TestGraphQLMissingScopeOutsideProjectItems(t *testing.T) {
+	//given a token missing a scope the issue itself needs
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"repository":null},"errors":[{"type":"INSUFFICIENT_SCOPES",` +
+			`"path":["repository"],"message":"Your token has not been granted the required scopes"}]}`))
+	}))
+	defer server.Close()
+	g := newTestGraphQLClient(server)
+
+	//when fetching the issue
+	_, err := g.GetIssue(context.Background(), examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}, "7", StaticToken("token"))
+
+	//then it fails as forbidden
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden but got: %v", err)
+	}
+}
+
+func TestGraphQLFindIssueFollowsCursor(t *testing.T) {
+	//given a repository whose issues are spread over two pages
+	var requests []graphQLRequest
+	server := newGraphQLStub(t, &requests,
+		graphQLAnswer{"...issueFields", `{"repository":{"issue":` + graphQLIssueData("OPEN") + `}}`},
+		graphQLAnswer{"issues(first: 100", `{"repository":{"issues":{"pageInfo":{"hasNextPage":true,"endCursor":"c1"},` +
+			`"nodes":[{"number":1,"title":"other"}]}}}`},
+		graphQLAnswer{"issues(first: 100", `{"repository":{"issues":{"pageInfo":{"hasNextPage":false,"endCursor":"c2"},` +
+			`"nodes":[{"number":7,"title":"testIssue"}]}}}`})
+	defer server.Close()
+	g := newTestGraphQLClient(server)
+
+	//when looking for the issue on the second page
+	issue, err := g.FindIssue(context.Background(), examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "testIssue"},
+		StaticToken("token"))
+
+	//then both pages are read and the matching issue is fetched
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if issue.IssueNumber != "7" || len(requests) != 3 || requests[1].Variables["cursor"] != "c1" {
+		t.Errorf("Expected issue 7 after two pages but got: %s, %v", issue.IssueNumber, requests)
+	}
+}
+
+func TestGraphQLCreateLooksUpIDs(t *testing.T) {
+	//given a github knowing the label, assignee and milestone of the spec
+	var requests []graphQLRequest
+	server := newGraphQLStub(t, &requests,
+		graphQLAnswer{"createIssue", `{"createIssue":{"issue":` + graphQLIssueData("OPEN") + `}}`},
+		graphQLAnswer{"label(name: $l0)", `{"repository":{"id":"R_1","l0":{"id":"LA_1"},"milestone":{"id":"MI_2"}},` +
+			`"a0":{"id":"U_1"}}`})
+	defer server.Close()
+	g := newTestGraphQLClient(server)
+	milestone := 2
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "testIssue", Description: "testing...",
+		Labels: []string{"bug"}, Assignees: []string{"octocat"}, Milestone: &milestone}
+
+	//when creating the issue
+	issue, err := g.Create(context.Background(), spec, StaticToken("token"))
+
+	//then the ids are looked up in one query and sent with createIssue
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if len(requests) != 2 || requests[0].Variables["l0"] != "bug" || requests[0].Variables["a0"] != "octocat" {
+		t.Fatalf("Expected a lookup and a mutation but got: %v", requests)
+	}
+	input := requests[1].Variables["input"].(map[string]interface{})
+	if input["repositoryId"] != "R_1" || fmt.Sprint(input["labelIds"]) != "[LA_1]" ||
+		fmt.Sprint(input["assigneeIds"]) != "[U_1]" || input["milestoneId"] != "MI_2" || input["title"] != "testIssue" {
+		t.Errorf("Expected the looked up ids in the input but got: %v", input)
+	}
+	if issue.IssueNumber != "7" {
+		t.Errorf("Expected the created issue but got: %+v", issue)
+	}
+}
+
+func TestGraphQLCreateWithUnknownLabel(t *testing.T) {
+	//given a github without the label of the spec
+	var requests []graphQLRequest
+	server := newGraphQLStub(t, &requests, graphQLAnswer{"label(name: $l0)", `{"repository":{"id":"R_1","l0":null}}`})
+	defer server.Close()
+	g := newTestGraphQLClient(server)
+
+	//when creating the issue
+	_, err := g.Create(context.Background(), examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "testIssue",
+		Labels: []string{"missing"}}, StaticToken("token"))
+
+	//then it fails validation without sending the mutation
+	if !errors.Is(err, ErrValidation) || len(requests) != 1 {
+		t.Errorf("Expected ErrValidation after the lookup only but got: %v, %d requests", err, len(requests))
+	}
+}
+
+func TestGraphQLEditClosesInTheSameRequest(t *testing.T) {
+	//given an open issue and a spec closing it as not planned
+	var requests []graphQLRequest
+	server := newGraphQLStub(t, &requests,
+		graphQLAnswer{"closeIssue", `{"updateIssue":{"issue":` + graphQLIssueData("OPEN") + `},` +
+			`"closeIssue":{"issue":` + graphQLIssueData("CLOSED") + `}}`},
+		graphQLAnswer{"issue(number: $number) { id state stateReason }",
+			`{"repository":{"id":"R_1","issue":{"id":"I_7","state":"OPEN","stateReason":null}}}`})
+	defer server.Close()
+	g := newTestGraphQLClient(server)
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "testIssue",
+		State: examplev1alpha1.IssueStateClosed, StateReason: examplev1alpha1.StateReasonNotPlanned}
+
+	//when editing the issue
+	issue, err := g.Edit(context.Background(), spec, "7", StaticToken("token"))
+
+	//then the fields are updated and the issue closed by one mutation request
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if len(requests) != 2 || !strings.Contains(requests[1].Query, "updateIssue") {
+		t.Fatalf("Expected a lookup and one mutation but got: %v", requests)
+	}
+	closeInput := requests[1].Variables["stateInput"].(map[string]interface{})
+	if closeInput["issueId"] != "I_7" || closeInput["stateReason"] != "NOT_PLANNED" {
+		t.Errorf("Expected issue I_7 closed as not planned but got: %v", closeInput)
+	}
+	if issue.State != "closed" {
+		t.Errorf("Expected the closed issue but got: %s", issue.State)
+	}
+}
+
+func TestGraphQLEditReopens(t *testing.T) {
+	//given a closed issue and a spec of an open issue
+	var requests []graphQLRequest
+	server := newGraphQLStub(t, &requests,
+		graphQLAnswer{"reopenIssue", `{"updateIssue":{"issue":` + graphQLIssueData("CLOSED") + `},` +
+			`"reopenIssue":{"issue":` + graphQLIssueData("OPEN") + `}}`},
+		graphQLAnswer{"issue(number: $number) { id state stateReason }",
+			`{"repository":{"id":"R_1","issue":{"id":"I_7","state":"CLOSED","stateReason":"COMPLETED"}}}`})
+	defer server.Close()
+	g := newTestGraphQLClient(server)
+
+	//when editing the issue
+	issue, err := g.Edit(context.Background(), examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "testIssue"}, "7",
+		StaticToken("token"))
+
+	//then it's reopened
+	if err != nil || issue.State != "open" {
+		t.Errorf("Expected the reopened issue but got: %v, %v", issue, err)
+	}
+}
+
+func TestGraphQLCommentRequests(t *testing.T) {
+	//given a github that records the comment mutations
+	var requests []graphQLRequest
+	server := newGraphQLStub(t, &requests,
+		graphQLAnswer{"addComment", `{"addComment":{"commentEdge":{"node":{"id":"IC_3","databaseId":3,"body":"new"}}}}`},
+		graphQLAnswer{"updateIssueComment", `{"updateIssueComment":{"issueComment":{"id":"IC_2","databaseId":2,"body":"edited"}}}`},
+		graphQLAnswer{"deleteIssueComment", `{"deleteIssueComment":{"clientMutationId":null}}`},
+		graphQLAnswer{"comments(first: 100", `{"repository":{"issue":{"comments":{"pageInfo":{"hasNextPage":false},` +
+			`"nodes":[{"id":"IC_2","databaseId":2,"body":"second"}]}}}}`},
+		graphQLAnswer{"issue(number: $number) { id state stateReason }",
+			`{"repository":{"id":"R_1","issue":{"id":"I_7","state":"OPEN"}}}`})
+	defer server.Close()
+	g := newTestGraphQLClient(server)
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+
+	//when listing, creating, editing and deleting comments
+	comments, listErr := g.ListComments(context.Background(), spec, "7", StaticToken("token"))
+	created, createErr := g.CreateComment(context.Background(), spec, "7", "new", StaticToken("token"))
+	if listErr != nil || createErr != nil || len(comments) != 1 {
+		t.Fatalf("Expected the comments but got: %v, %v, %v", comments, listErr, createErr)
+	}
+	edited, editErr := g.EditComment(context.Background(), spec, comments[0], "edited", StaticToken("token"))
+	deleteErr := g.DeleteComment(context.Background(), spec, comments[0], StaticToken("token"))
+
+	//then the comments are addressed by their node ids and returned with their database ids
+	if editErr != nil || deleteErr != nil {
+		t.Fatalf("Expected no errors but got: %v, %v", editErr, deleteErr)
+	}
+	if created.ID != 3 || created.NodeID != "IC_3" || edited.Body != "edited" {
+		t.Errorf("Expected the created and edited comments but got: %v, %v", created, edited)
+	}
+	addInput := requests[2].Variables["input"].(map[string]interface{})
+	deleteInput := requests[4].Variables["input"].(map[string]interface{})
+	if addInput["subjectId"] != "I_7" || deleteInput["id"] != "IC_2" {
+		t.Errorf("Expected node ids in the mutations but got: %v, %v", addInput, deleteInput)
+	}
+}
+
+func TestGraphQLFindIssueByMarker(t *testing.T) {
+	//given a repository where issue #7 holds the marker, among the issues updated since the object was created
+	var requests []graphQLRequest
+	server := newGraphQLStub(t, &requests,
+		graphQLAnswer{"...issueFields", `{"repository":{"issue":` + graphQLIssueData("OPEN") + `}}`},
+		graphQLAnswer{"filterBy: {since: $since}", `{"repository":{"issues":{"pageInfo":{"hasNextPage":false},` +
+			`"nodes":[{"number":8,"body":"someone else's"},{"number":7,"body":"testing...\n\n<!-- marker -->"}]}}}`})
+	defer server.Close()
+	g := newTestGraphQLClient(server)
+
+	//when looking for the marker
+	since := time.Date(2021, 5, 30, 9, 0, 0, 0, time.UTC)
+	issue, err := g.FindIssueByMarker(context.Background(), examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"},
+		"<!-- marker -->", since, StaticToken("token"))
+
+	//then the issue holding it is fetched, having listed only the issues updated since
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if issue.IssueNumber != "7" || requests[0].Variables["since"] != "2021-05-30T09:00:00Z" {
+		t.Errorf("Expected issue 7 listed since the given time but got: %s, %v", issue.IssueNumber, requests)
+	}
+}
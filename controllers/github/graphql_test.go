@@ -0,0 +1,113 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+)
+
+func TestGraphQLURL(t *testing.T) {
+	tests := map[string]string{
+		"":                                   "https://api.github.com/graphql",
+		"https://api.github.com":             "https://api.github.com/graphql",
+		"https://github.example.com/api/v3":  "https://github.example.com/api/graphql",
+		"https://github.example.com/api/v3/": "https://github.example.com/api/graphql",
+	}
+	for baseURL, expected := range tests {
+		if graphQLURL := GraphQLURL(baseURL); graphQLURL != expected {
+			t.Errorf("Expected %s for %q but got: %s", expected, baseURL, graphQLURL)
+		}
+	}
+}
+
+func TestTransferIssue(t *testing.T) {
+	//given a github that transfers issue #1 of testUser/testRepo to testUser/otherRepo as #5
+	var variables []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			if r.URL.Path != "/repos/testUser/otherRepo/issues/5" {
+				t.Errorf("Expected the transferred issue to be fetched but got: %s", r.URL.Path)
+			}
+			_, _ = w.Write([]byte(`{"number":5,"title":"title","html_url":"https://github.com/testUser/otherRepo/issues/5"}`))
+			return
+		}
+		var request graphQLRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		variables = append(variables, request.Variables)
+		if strings.HasPrefix(request.Query, "mutation") {
+			_, _ = w.Write([]byte(`{"data":{"transferIssue":{"issue":{"number":5}}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"repository":{"issue":{"id":"I_1"}},"target":{"id":"R_2"}}}`))
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+
+	//when transferring the issue
+	issue, err := c.TransferIssue(context.Background(), spec, "1", "testUser/otherRepo", StaticToken("token"))
+
+	//then the ids are looked up, the mutation is sent with them and the issue is returned from its new repo
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if len(variables) != 2 || variables[0]["targetName"] != "otherRepo" || variables[0]["number"] != float64(1) ||
+		variables[1]["issueId"] != "I_1" || variables[1]["repositoryId"] != "R_2" {
+		t.Errorf("Expected the lookup and the mutation but got: %v", variables)
+	}
+	if issue.IssueNumber != "5" || issue.HTMLURL != "https://github.com/testUser/otherRepo/issues/5" {
+		t.Errorf("Expected issue #5 of testUser/otherRepo but got: %+v", issue)
+	}
+}
+
+func TestTransferIssueNotFound(t *testing.T) {
+	//given a github answering the lookup with a graphql error
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"repository":null},"errors":[{"type":"NOT_FOUND","message":"Could not resolve to a Repository"}]}`))
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+
+	//when transferring the issue
+	_, err := c.TransferIssue(context.Background(), examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}, "1", "testUser/otherRepo",
+		StaticToken("token"))
+
+	//then the error is a not found error
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound but got: %v", err)
+	}
+}
+
+func TestLocateIssue(t *testing.T) {
+	//given a github where the issue with node id I_1 was transferred to testUser/otherRepo as #5
+	var variables map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request graphQLRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		variables = request.Variables
+		_, _ = w.Write([]byte(`{"data":{"node":{"number":5,"repository":{"nameWithOwner":"testUser/otherRepo"}}}}`))
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+
+	//when locating the issue from its former repo
+	repo, number, err := c.LocateIssue(context.Background(), examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}, "I_1",
+		StaticToken("token"))
+
+	//then the issue is found where it is now
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if variables["id"] != "I_1" {
+		t.Errorf("Expected the node id to be sent but got: %v", variables)
+	}
+	if repo != "testUser/otherRepo" || number != "5" {
+		t.Errorf("Expected #5 of testUser/otherRepo but got: #%s of %s", number, repo)
+	}
+}
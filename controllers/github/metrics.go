@@ -0,0 +1,115 @@
+package github
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_api_requests_total",
+		Help: "Number of requests sent to the GitHub API by method, endpoint and status code.",
+	}, []string{"method", "endpoint", "code"})
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "github_api_request_duration_seconds",
+		Help:    "Duration of the requests sent to the GitHub API by method, endpoint and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "endpoint", "code"})
+	rateLimitRemaining = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "github_api_rate_limit_remaining",
+		Help: "Requests left in the current GitHub rate limit window by credential, the installation of a GitHub App " +
+			"or a digest of the token, and by resource, e.g. core or graphql.",
+	}, []string{"credential", "resource"})
+	rateLimitReset = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "github_api_rate_limit_reset_timestamp_seconds",
+		Help: "Unix time the current GitHub rate limit window resets by credential, the installation of a GitHub App " +
+			"or a digest of the token, and by resource, e.g. core or graphql.",
+	}, []string{"credential", "resource"})
+	cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_api_cache_lookups_total",
+		Help: "Number of GET requests sent to the GitHub API with the response cache by result, " +
+			"hit for a 304 answered from the cache and miss otherwise.",
+	}, []string{"result"})
+	retriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "github_api_retries_total",
+		Help: "Number of requests to the GitHub API sent again after a transient failure by method, endpoint and " +
+			"reason, server_error for a 5xx response and error when no response was received.",
+	}, []string{"method", "endpoint", "reason"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(requestsTotal, requestDuration, rateLimitRemaining, rateLimitReset, cacheLookups,
+		retriesTotal)
+}
+
+var (
+	// ownerRepoPath and numberPath turn request paths into endpoints, keeping the label values bounded
+	ownerRepoPath = regexp.MustCompile(`/repos/[^/]+/[^/]+`)
+	numberPath    = regexp.MustCompile(`/[0-9]+(/|$)`)
+)
+
+// endpoint: the path of the request with the repo and the numbers replaced by placeholders
+func endpoint(apiURL string) string {
+	parsed, err := url.Parse(apiURL)
+	if err != nil {
+		return "unknown"
+	}
+	path := ownerRepoPath.ReplaceAllString(parsed.Path, "/repos/{owner}/{repo}")
+	return numberPath.ReplaceAllString(path, "/{number}$1")
+}
+
+// observeRequest: record a request sent to github, resp is nil when no response was received
+func observeRequest(method, apiURL, token string, resp *http.Response, start time.Time) {
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+		if remaining := headerInt(resp.Header, "X-RateLimit-Remaining", -1); remaining >= 0 {
+			observeRateLimit(credentialID(token), rateLimitResource(apiURL, resp), remaining,
+				int64(headerInt(resp.Header, "X-RateLimit-Reset", 0)))
+		}
+	}
+	labels := []string{method, endpoint(apiURL), code}
+	requestsTotal.WithLabelValues(labels...).Inc()
+	requestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+}
+
+// rateLimitWindows is the reset of the window last reported for every quota with rate limit series
+var rateLimitWindows = struct {
+	sync.Mutex
+	resets map[rateLimitKey]int64
+}{resets: map[rateLimitKey]int64{}}
+
+// observeRateLimit: record the rate limit of a credential for a resource, and delete the series of the other
+// quotas whose window is over. those tell nothing anymore, and would pile up as tokens are rotated
+func observeRateLimit(credential, resource string, remaining int, reset int64) {
+	rateLimitWindows.Lock()
+	defer rateLimitWindows.Unlock()
+	key := rateLimitKey{credential, resource}
+	now := time.Now().Unix()
+	for other, otherReset := range rateLimitWindows.resets {
+		if other != key && otherReset < now {
+			rateLimitRemaining.DeleteLabelValues(other.credential, other.resource)
+			rateLimitReset.DeleteLabelValues(other.credential, other.resource)
+			delete(rateLimitWindows.resets, other)
+		}
+	}
+	rateLimitWindows.resets[key] = reset
+	rateLimitRemaining.WithLabelValues(credential, resource).Set(float64(remaining))
+	rateLimitReset.WithLabelValues(credential, resource).Set(float64(reset))
+}
+
+// observeCacheLookup: record whether a request sent with the response cache was answered from it
+func observeCacheLookup(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookups.WithLabelValues(result).Inc()
+}
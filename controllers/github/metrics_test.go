@@ -0,0 +1,82 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+)
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		apiURL   string
+		expected string
+	}{
+		{"https://api.github.com/repos/testUser/testRepo/issues?per_page=100", "/repos/{owner}/{repo}/issues"},
+		{"https://api.github.com/repos/testUser/testRepo/issues/12", "/repos/{owner}/{repo}/issues/{number}"},
+		{"https://api.github.com/repos/testUser/testRepo/issues/12/comments", "/repos/{owner}/{repo}/issues/{number}/comments"},
+		{"https://github.example.com/api/v3/app/installations/42/access_tokens", "/api/v3/app/installations/{number}/access_tokens"},
+	}
+	for _, test := range tests {
+		if got := endpoint(test.apiURL); got != test.expected {
+			t.Errorf("Expected endpoint of %s to be %s but got: %s", test.apiURL, test.expected, got)
+		}
+	}
+}
+
+func TestRequestsAreMeasured(t *testing.T) {
+	//given a github that answers with a rate limit
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", "1700000000")
+		_, _ = w.Write([]byte(`{"number":7,"title":"testIssue"}`))
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+	counter := requestsTotal.WithLabelValues("GET", "/repos/{owner}/{repo}/issues/{number}", "200")
+	before := testutil.ToFloat64(counter)
+
+	//when getting an issue
+	_, err := c.GetIssue(context.Background(), examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}, "7", StaticToken("metrics-token"))
+
+	//then the request and the rate limit are recorded
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if got := testutil.ToFloat64(counter) - before; got != 1 {
+		t.Errorf("Expected one request to be counted but got: %v", got)
+	}
+	credential := credentialKey("metrics-token")
+	if got := testutil.ToFloat64(rateLimitRemaining.WithLabelValues(credential, "core")); got != 4999 {
+		t.Errorf("Expected 4999 requests remaining but got: %v", got)
+	}
+	if got := testutil.ToFloat64(rateLimitReset.WithLabelValues(credential, "core")); got != 1700000000 {
+		t.Errorf("Expected the reset time but got: %v", got)
+	}
+}
+
+func TestRateLimitSeriesFollowCredentials(t *testing.T) {
+	//given an app installation whose token was replaced, and a token whose window is over
+	credentialNames.replace("", "first-installation-token", "installation-1@api.github.com")
+	credentialNames.replace("first-installation-token", "second-installation-token", "installation-1@api.github.com")
+	observeRateLimit(credentialKey("rotated-token"), "core", 10, time.Now().Add(-time.Minute).Unix())
+
+	//when github reports the rate limit of the installation's new token
+	observeRateLimit(credentialID("second-installation-token"), "core", 4000, time.Now().Add(time.Hour).Unix())
+
+	//then the installation keeps a single series, and the series of the over window are deleted
+	if got := testutil.ToFloat64(rateLimitRemaining.WithLabelValues("installation-1@api.github.com", "core")); got != 4000 {
+		t.Errorf("Expected 4000 requests remaining for the installation but got: %v", got)
+	}
+	if credentialID("first-installation-token") != credentialKey("first-installation-token") {
+		t.Error("Expected the replaced token to be forgotten")
+	}
+	if rateLimitRemaining.DeleteLabelValues(credentialKey("rotated-token"), "core") {
+		t.Error("Expected the series of the over window to be deleted")
+	}
+}
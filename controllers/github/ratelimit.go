@@ -0,0 +1,209 @@
+package github
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secondaryRateLimitWait is how long to back off from a secondary (abuse) rate limit that came without a
+// Retry-After header, github asks to wait at least a minute
+const secondaryRateLimitWait = time.Minute
+
+// RateLimitError is returned without calling github when the tracker knows the rate limit is exhausted
+type RateLimitError struct {
+	Reset     time.Time
+	Secondary bool
+}
+
+func (e *RateLimitError) Error() string {
+	kind := "primary"
+	if e.Secondary {
+		kind = "secondary"
+	}
+	return fmt.Sprintf("github %s rate limit exceeded until %s", kind, e.Reset.Format(time.RFC3339))
+}
+
+// Is lets errors.Is match a RateLimitError with ErrRateLimited
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// RateLimitWait reports whether err is a rate limit error, and if so how long to wait before calling
+// github again
+func RateLimitWait(err error) (time.Duration, bool) {
+	if !errors.Is(err, ErrRateLimited) {
+		return 0, false
+	}
+	var limitErr *RateLimitError
+	if errors.As(err, &limitErr) {
+		return untilReset(limitErr.Reset), true
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.RetryAfter > 0 {
+			return apiErr.RetryAfter, true
+		}
+		if apiErr.RateLimitRemaining == 0 && !apiErr.RateLimitReset.IsZero() {
+			return untilReset(apiErr.RateLimitReset), true
+		}
+	}
+	return secondaryRateLimitWait, true
+}
+
+// untilReset: time left until reset, with a second of slack for clock skew with github
+func untilReset(reset time.Time) time.Duration {
+	wait := time.Until(reset) + time.Second
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait
+}
+
+// RateLimitTracker follows github's rate limit headers for every credential, so that calls are stopped
+// before the quota runs out instead of failing on github. it is safe to share between reconciles
+type RateLimitTracker struct {
+	// Reserve is the number of requests left unused in every quota window
+	Reserve int
+
+	mu     sync.Mutex
+	limits map[rateLimitKey]rateLimitState
+}
+
+// rateLimitKey is a quota of github. every credential has one per resource, e.g. core for the rest api and
+// graphql, each with its own remaining requests and reset
+type rateLimitKey struct {
+	credential string
+	resource   string
+}
+
+type rateLimitState struct {
+	known        bool
+	remaining    int
+	reset        time.Time
+	blockedUntil time.Time
+}
+
+func NewRateLimitTracker(reserve int) *RateLimitTracker {
+	return &RateLimitTracker{
+		Reserve: reserve,
+		limits:  map[rateLimitKey]rateLimitState{},
+	}
+}
+
+// Check returns a *RateLimitError when a call with the token would go over the rate limit of the resource
+func (t *RateLimitTracker) Check(token, resource string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	state := t.limits[rateLimitKey{credentialID(token), resource}]
+	now := time.Now()
+	if now.Before(state.blockedUntil) {
+		return &RateLimitError{Reset: state.blockedUntil, Secondary: true}
+	}
+	if state.known && state.remaining <= t.Reserve && now.Before(state.reset) {
+		return &RateLimitError{Reset: state.reset}
+	}
+	return nil
+}
+
+// Update records the rate limit of the resource github reported on a response made with the token. err is the
+// error the response was turned into, if any
+func (t *RateLimitTracker) Update(token, resource string, resp *http.Response, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := rateLimitKey{credentialID(token), resource}
+	t.prune(time.Now())
+	state := t.limits[key]
+	if remaining := headerInt(resp.Header, "X-RateLimit-Remaining", -1); remaining >= 0 {
+		state.known = true
+		state.remaining = remaining
+		state.reset = time.Unix(int64(headerInt(resp.Header, "X-RateLimit-Reset", 0)), 0)
+	}
+	// secondary limits don't show in the headers above, block the credential for as long as github asked
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.isRateLimit() && apiErr.RateLimitRemaining != 0 {
+		wait, _ := RateLimitWait(err)
+		state.blockedUntil = time.Now().Add(wait)
+	}
+	t.limits[key] = state
+}
+
+// prune : forget the credentials whose quota window is over and that aren't blocked, there's nothing left to
+// know about them (e.g. a token rotated out of a secret)
+func (t *RateLimitTracker) prune(now time.Time) {
+	for key, state := range t.limits {
+		if !now.Before(state.reset) && !now.Before(state.blockedUntil) {
+			delete(t.limits, key)
+		}
+	}
+}
+
+// rateLimitResource : the quota a request to the api url counts against, as github reports it in the
+// X-RateLimit-Resource header of the response, or as told by the url before there is one
+func rateLimitResource(apiURL string, resp *http.Response) string {
+	if resp != nil {
+		if resource := resp.Header.Get("X-RateLimit-Resource"); resource != "" {
+			return resource
+		}
+	}
+	path := apiURL
+	if parsed, err := url.Parse(apiURL); err == nil {
+		path = parsed.Path
+	}
+	switch {
+	case strings.HasSuffix(path, "/graphql"):
+		return "graphql"
+	case strings.Contains(path, "/search/"):
+		return "search"
+	}
+	return "core"
+}
+
+// credentialRegistry names the tokens that stand for a longer lived credential, e.g. the installation tokens
+// of a github app which are replaced every hour while github meters the installation
+type credentialRegistry struct {
+	mu    sync.Mutex
+	names map[string]string
+}
+
+// credentialNames are the names of the tokens the app token sources created and still use
+var credentialNames = &credentialRegistry{names: map[string]string{}}
+
+// replace : name the token, forgetting the one it replaces
+func (r *credentialRegistry) replace(oldToken, token, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if oldToken != "" {
+		delete(r.names, credentialKey(oldToken))
+	}
+	r.names[credentialKey(token)] = name
+}
+
+// name : the name of the token, false when it doesn't stand for another credential
+func (r *credentialRegistry) name(token string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name, ok := r.names[credentialKey(token)]
+	return name, ok
+}
+
+// credentialID : what the calls with the token are tracked by, the credential the token belongs to when it
+// has a name and a digest of the token otherwise
+func credentialID(token string) string {
+	if name, ok := credentialNames.name(token); ok {
+		return name
+	}
+	return credentialKey(token)
+}
+
+// credentialKey: the tracker keeps a digest of the token rather than the token itself
+func credentialKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:8])
+}
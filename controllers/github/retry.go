@@ -0,0 +1,189 @@
+package github
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultRetryInitialInterval is how long the first retry of a failed request waits
+	DefaultRetryInitialInterval = 500 * time.Millisecond
+	// DefaultRetryMaxInterval caps the wait between two retries
+	DefaultRetryMaxInterval = 10 * time.Second
+	// DefaultRetryMaxElapsedTime is how long a request keeps being retried, it stays below DefaultRequestTimeout
+	// so that the last attempt has time to complete
+	DefaultRetryMaxElapsedTime = 20 * time.Second
+)
+
+// retryJitter is the fraction of the backoff interval the wait is randomized by, so that reconciles failing
+// together don't retry together
+const retryJitter = 0.5
+
+// RetryPolicy is how a RetryTransport backs off between the attempts of a request
+type RetryPolicy struct {
+	// InitialInterval is the wait before the first retry, doubled for every following one
+	InitialInterval time.Duration
+	// MaxInterval caps the wait between two retries
+	MaxInterval time.Duration
+	// MaxElapsedTime is how long after the first attempt a request may still be retried, 0 turns retries off.
+	// retries also stop at the deadline of the request's context
+	MaxElapsedTime time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used by the operator when no flags override it
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		InitialInterval: DefaultRetryInitialInterval,
+		MaxInterval:     DefaultRetryMaxInterval,
+		MaxElapsedTime:  DefaultRetryMaxElapsedTime,
+	}
+}
+
+// backoff : the wait before the given retry, 0 for the first one, growing exponentially up to MaxInterval and
+// randomized by retryJitter. random returns a number in [0, 1)
+func (p RetryPolicy) backoff(retry int, random func() float64) time.Duration {
+	interval := p.InitialInterval
+	for i := 0; i < retry && interval < p.MaxInterval; i++ {
+		interval *= 2
+	}
+	if p.MaxInterval > 0 && interval > p.MaxInterval {
+		interval = p.MaxInterval
+	}
+	delta := retryJitter * float64(interval)
+	return time.Duration(float64(interval) - delta + 2*delta*random())
+}
+
+// RetryTransport sends requests with Base and retries the ones that are safe to send again when github answers
+// with a server error or the connection fails. a Retry-After header sent with the error is honored. requests
+// that aren't idempotent (e.g. creating an issue) are never retried, as the first attempt may have gone through
+type RetryTransport struct {
+	// Base sends every attempt, http.DefaultTransport when it's nil
+	Base   http.RoundTripper
+	Policy RetryPolicy
+
+	// random and sleep are replaced in tests
+	random func() float64
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetryTransport returns a transport retrying the requests sent with base according to the policy
+func NewRetryTransport(base http.RoundTripper, policy RetryPolicy) *RetryTransport {
+	return &RetryTransport{Base: base, Policy: policy}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Policy.MaxElapsedTime <= 0 || !retryable(req) {
+		return base.RoundTrip(req)
+	}
+	random, sleep := t.random, t.sleep
+	if random == nil {
+		random = rand.Float64
+	}
+	if sleep == nil {
+		sleep = sleepContext
+	}
+
+	start := time.Now()
+	attempt := req
+	for retry := 0; ; retry++ {
+		resp, err := base.RoundTrip(attempt)
+		reason, transient := transientFailure(req, resp, err)
+		if !transient {
+			return resp, err
+		}
+		wait := t.Policy.backoff(retry, random)
+		if resp != nil {
+			if retryAfter := headerInt(resp.Header, "Retry-After", 0); retryAfter > 0 {
+				wait = time.Duration(retryAfter) * time.Second
+			}
+		}
+		if time.Since(start)+wait > t.Policy.MaxElapsedTime {
+			return resp, err
+		}
+		// the body of the request is read by every attempt, the next one needs a fresh copy
+		next := req
+		if req.Body != nil && req.Body != http.NoBody {
+			next = req.Clone(req.Context())
+			if next.Body, err = req.GetBody(); err != nil {
+				return resp, err
+			}
+		}
+		if resp != nil {
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		retriesTotal.WithLabelValues(req.Method, endpoint(req.URL.String()), reason).Inc()
+		if err = sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+		attempt = next
+	}
+}
+
+// idempotentKey marks the context of a request that is safe to retry although its method isn't idempotent
+type idempotentKey struct{}
+
+// withIdempotent : mark the requests sent with the context as safe to retry, e.g. graphql queries that are
+// POSTed but don't change anything
+func withIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// retryable : whether sending the request again can't do anything the first attempt didn't, and its body can
+// be sent again
+func retryable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	idempotent, _ := req.Context().Value(idempotentKey{}).(bool)
+	return idempotent
+}
+
+// transientFailure : whether the attempt failed in a way another attempt may not, with the reason it failed
+func transientFailure(req *http.Request, resp *http.Response, err error) (string, bool) {
+	if err != nil {
+		// a cancelled reconcile or a timed out request isn't retried, nor is a certificate that won't be trusted
+		// the next time either
+		if req.Context().Err() != nil {
+			return "", false
+		}
+		var unknownAuthority x509.UnknownAuthorityError
+		var invalidCertificate x509.CertificateInvalidError
+		var hostname x509.HostnameError
+		if errors.As(err, &unknownAuthority) || errors.As(err, &invalidCertificate) || errors.As(err, &hostname) {
+			return "", false
+		}
+		return "error", true
+	}
+	if resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented {
+		return "server_error", true
+	}
+	return "", false
+}
+
+// sleepContext : wait for the duration, or until the context is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,256 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+)
+
+// newFlakyServer fails the first failures requests with the status, then answers with the body
+func newFlakyServer(failures, status int, body string, bodies *[]string) *httptest.Server {
+	requests := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestBody, _ := ioutil.ReadAll(r.Body)
+		*bodies = append(*bodies, string(requestBody))
+		requests++
+		if requests <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+// newTestRetryClient returns a client retrying right away, recording the waits it was asked for
+func newTestRetryClient(server *httptest.Server, policy RetryPolicy, waits *[]time.Duration) *ClientAPI {
+	transport := NewRetryTransport(nil, policy)
+	transport.random = func() float64 { return 0.5 }
+	transport.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return nil
+	}
+	return &ClientAPI{BaseURL: server.URL, HTTPClient: &http.Client{Transport: transport}}
+}
+
+func TestRetryOnServerError(t *testing.T) {
+	//given github failing twice with a 502 before answering
+	var bodies []string
+	server := newFlakyServer(2, http.StatusBadGateway, `{"title": "flaky", "number": 1}`, &bodies)
+	defer server.Close()
+	var waits []time.Duration
+	c := newTestRetryClient(server, DefaultRetryPolicy(), &waits)
+
+	//when getting the issue
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+	issue, err := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+
+	//then the request is sent again, backing off exponentially
+	if err != nil {
+		t.Fatalf("Expected the retried request to succeed but got: %v", err)
+	}
+	if issue.Title != "flaky" || len(bodies) != 3 {
+		t.Fatalf("Expected the issue after 3 attempts but got %+v after %d", issue, len(bodies))
+	}
+	if len(waits) != 2 || waits[0] != DefaultRetryInitialInterval || waits[1] != 2*DefaultRetryInitialInterval {
+		t.Fatalf("Expected waits of %v and %v but got: %v", DefaultRetryInitialInterval, 2*DefaultRetryInitialInterval, waits)
+	}
+}
+
+func TestNoRetryOfCreate(t *testing.T) {
+	//given github failing a request with a 502, after which the issue may have been created anyway
+	var bodies []string
+	server := newFlakyServer(1, http.StatusBadGateway, `{"title": "created", "number": 1}`, &bodies)
+	defer server.Close()
+	var waits []time.Duration
+	c := newTestRetryClient(server, DefaultRetryPolicy(), &waits)
+
+	//when creating an issue
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "created"}
+	_, err := c.Create(context.Background(), spec, StaticToken("token"))
+
+	//then the POST isn't sent again
+	if err == nil {
+		t.Fatal("Expected the failure to be returned")
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("Expected a single attempt but got %d", len(bodies))
+	}
+}
+
+func TestRetryResendsBody(t *testing.T) {
+	//given github failing a graphql query once
+	var bodies []string
+	server := newFlakyServer(1, http.StatusServiceUnavailable, `{"data": {}}`, &bodies)
+	defer server.Close()
+	var waits []time.Duration
+	c := newTestRetryClient(server, DefaultRetryPolicy(), &waits)
+
+	//when sending the query
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", BaseURL: server.URL}
+	err := c.graphQL(context.Background(), spec, "token", "query { viewer { login } }", nil, nil)
+
+	//then the query is sent again with its body
+	if err != nil {
+		t.Fatalf("Expected the retried query to succeed but got: %v", err)
+	}
+	if len(bodies) != 2 || bodies[0] == "" || bodies[0] != bodies[1] {
+		t.Fatalf("Expected the same body twice but got: %q", bodies)
+	}
+}
+
+func TestNoRetryOfMutation(t *testing.T) {
+	//given github failing a graphql mutation
+	var bodies []string
+	server := newFlakyServer(1, http.StatusServiceUnavailable, `{"data": {}}`, &bodies)
+	defer server.Close()
+	var waits []time.Duration
+	c := newTestRetryClient(server, DefaultRetryPolicy(), &waits)
+
+	//when sending the mutation
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", BaseURL: server.URL}
+	err := c.graphQL(context.Background(), spec, "token", "mutation { closeIssue { clientMutationId } }", nil, nil)
+
+	//then it isn't sent again
+	if err == nil || len(bodies) != 1 {
+		t.Fatalf("Expected a single failed attempt but got %d: %v", len(bodies), err)
+	}
+}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	//given github asking to come back in 3 seconds
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"number": 1}`))
+	}))
+	defer server.Close()
+	var waits []time.Duration
+	c := newTestRetryClient(server, DefaultRetryPolicy(), &waits)
+
+	//when getting an issue
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+	_, err := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+
+	//then the retry waits as long as github asked
+	if err != nil {
+		t.Fatalf("Expected the retried request to succeed but got: %v", err)
+	}
+	if len(waits) != 1 || waits[0] != 3*time.Second {
+		t.Fatalf("Expected to wait 3s but got: %v", waits)
+	}
+}
+
+func TestRetryStopsAfterMaxElapsedTime(t *testing.T) {
+	//given github failing every request and asking to come back later than the policy allows
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	var waits []time.Duration
+	c := newTestRetryClient(server, DefaultRetryPolicy(), &waits)
+
+	//when getting an issue
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+	_, err := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+
+	//then the server error is returned without retrying
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected the 503 to be returned but got: %v", err)
+	}
+	if requests != 1 || len(waits) != 0 {
+		t.Fatalf("Expected a single attempt but got %d", requests)
+	}
+}
+
+// failingTransport fails the first failures round trips with a connection error
+type failingTransport struct {
+	failures int
+	attempts int
+}
+
+func (f *failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.attempts++
+	if f.attempts <= f.failures {
+		return nil, errors.New("read: connection reset by peer")
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(`{"number": 1}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestRetryOnNetworkError(t *testing.T) {
+	//given a connection reset on the first attempt
+	base := &failingTransport{failures: 1}
+	transport := NewRetryTransport(base, DefaultRetryPolicy())
+	transport.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+	c := &ClientAPI{BaseURL: "https://api.github.test", HTTPClient: &http.Client{Transport: transport}}
+
+	//when getting an issue
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+	_, err := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+
+	//then the request is sent again
+	if err != nil || base.attempts != 2 {
+		t.Fatalf("Expected success on the second attempt but got %d attempts: %v", base.attempts, err)
+	}
+}
+
+func TestRetryOff(t *testing.T) {
+	//given a policy without a max elapsed time
+	base := &failingTransport{failures: 1}
+	transport := NewRetryTransport(base, RetryPolicy{})
+	c := &ClientAPI{BaseURL: "https://api.github.test", HTTPClient: &http.Client{Transport: transport}}
+
+	//when a request fails
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+	_, err := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+
+	//then it isn't retried
+	if err == nil || base.attempts != 1 {
+		t.Fatalf("Expected a single failed attempt but got %d: %v", base.attempts, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialInterval: time.Second, MaxInterval: 5 * time.Second}
+	for _, tc := range []struct {
+		retry    int
+		random   float64
+		expected time.Duration
+	}{
+		{retry: 0, random: 0.5, expected: time.Second},
+		{retry: 1, random: 0.5, expected: 2 * time.Second},
+		{retry: 2, random: 0.5, expected: 4 * time.Second},
+		{retry: 3, random: 0.5, expected: 5 * time.Second},
+		{retry: 30, random: 0.5, expected: 5 * time.Second},
+		{retry: 0, random: 0, expected: 500 * time.Millisecond},
+		{retry: 1, random: 0.75, expected: 2500 * time.Millisecond},
+	} {
+		//when backing off
+		wait := policy.backoff(tc.retry, func() float64 { return tc.random })
+
+		//then the interval doubles up to the max and is jittered by half of it
+		if wait != tc.expected {
+			t.Errorf("retry %d with random %v: expected %v but got %v", tc.retry, tc.random, tc.expected, wait)
+		}
+	}
+}
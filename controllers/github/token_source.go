@@ -0,0 +1,294 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TokenSource provides the token authorizing calls to github for a repository ("owner/name")
+type TokenSource interface {
+	Token(ctx context.Context, repo string) (string, error)
+}
+
+// StaticToken is a TokenSource that always returns the same token, e.g. a personal access token
+type StaticToken string
+
+func (t StaticToken) Token(ctx context.Context, repo string) (string, error) {
+	return string(t), nil
+}
+
+// appJWTLifetime: github accepts app JWTs that expire at most 10 minutes after they were issued
+const appJWTLifetime = 9 * time.Minute
+
+// installationTokenRefreshMargin: an installation token is refreshed when it expires within this margin
+const installationTokenRefreshMargin = 5 * time.Minute
+
+// AppTokenSource authenticates as a github app installation. it signs a JWT with the app's private key,
+// exchanges it for an installation token of the installation the repository belongs to, and caches that
+// token until shortly before it expires
+type AppTokenSource struct {
+	AppID int64
+	// InstallationID (optional) skips looking up the installation of every repository
+	InstallationID int64
+	PrivateKey     *rsa.PrivateKey
+	// BaseURL of the github api, defaults to APIBaseURL
+	BaseURL    string
+	HTTPClient *http.Client
+	// Timeout of every request, DefaultRequestTimeout when it isn't set
+	Timeout time.Duration
+
+	mu            sync.Mutex
+	installations map[string]int64
+	tokens        map[int64]installationToken
+	minting       map[int64]*tokenRequest
+}
+
+// tokenRequest is an installation token being created, the reconciles needing it meanwhile wait for it
+// instead of creating their own
+type tokenRequest struct {
+	done  chan struct{}
+	token installationToken
+	err   error
+}
+
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type installation struct {
+	ID int64 `json:"id"`
+}
+
+// Token returns a valid installation token for the installation of the repository. the lock is never held
+// while calling github: a cached token is returned right away, and a token being created is waited for
+func (s *AppTokenSource) Token(ctx context.Context, repo string) (string, error) {
+	installationID, err := s.installationID(ctx, repo)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	if token, ok := s.tokens[installationID]; ok && time.Until(token.ExpiresAt) > installationTokenRefreshMargin {
+		s.mu.Unlock()
+		return token.Token, nil
+	}
+	if request, ok := s.minting[installationID]; ok {
+		s.mu.Unlock()
+		select {
+		case <-request.done:
+			return request.token.Token, request.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	request := &tokenRequest{done: make(chan struct{})}
+	if s.minting == nil {
+		s.minting = map[int64]*tokenRequest{}
+	}
+	s.minting[installationID] = request
+	s.mu.Unlock()
+
+	request.token, request.err = s.createToken(ctx, repo, installationID)
+
+	s.mu.Lock()
+	delete(s.minting, installationID)
+	if request.err == nil {
+		if s.tokens == nil {
+			s.tokens = map[int64]installationToken{}
+		}
+		credentialNames.replace(s.tokens[installationID].Token, request.token.Token, s.credentialName(installationID))
+		s.tokens[installationID] = request.token
+	}
+	s.mu.Unlock()
+	close(request.done)
+	return request.token.Token, request.err
+}
+
+// createToken: create an installation token. an installation github no longer knows (e.g. the app was
+// reinstalled) is forgotten, so that the next call looks the repository's installation up again
+func (s *AppTokenSource) createToken(ctx context.Context, repo string, installationID int64) (installationToken, error) {
+	var token installationToken
+	apiURL := s.baseURL() + "app/installations/" + strconv.FormatInt(installationID, 10) + "/access_tokens"
+	if err := s.doAsApp(ctx, "POST", apiURL, &token); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			s.mu.Lock()
+			delete(s.installations, repo)
+			s.mu.Unlock()
+		}
+		return installationToken{}, fmt.Errorf("creating installation token for app %d: %w", s.AppID, err)
+	}
+	return token, nil
+}
+
+// installationID: the configured installation, or the one of the repository as github reports it
+func (s *AppTokenSource) installationID(ctx context.Context, repo string) (int64, error) {
+	if s.InstallationID != 0 {
+		return s.InstallationID, nil
+	}
+	s.mu.Lock()
+	id, ok := s.installations[repo]
+	s.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+	var found installation
+	if err := s.doAsApp(ctx, "GET", s.baseURL()+"repos/"+repo+"/installation", &found); err != nil {
+		return 0, fmt.Errorf("finding installation of app %d for %s: %w", s.AppID, repo, err)
+	}
+	s.mu.Lock()
+	if s.installations == nil {
+		s.installations = map[string]int64{}
+	}
+	s.installations[repo] = found.ID
+	s.mu.Unlock()
+	return found.ID, nil
+}
+
+// credentialName: the name the calls with the tokens of the installation are tracked by, github meters them by
+// installation rather than by token
+func (s *AppTokenSource) credentialName(installationID int64) string {
+	host := s.baseURL()
+	if parsed, err := url.Parse(host); err == nil && parsed.Host != "" {
+		host = parsed.Host
+	}
+	return fmt.Sprintf("installation-%d@%s", installationID, host)
+}
+
+// doAsApp: call github authenticated as the app itself (with a JWT) and decode the response into result
+func (s *AppTokenSource) doAsApp(ctx context.Context, method, apiURL string, result interface{}) error {
+	jwt, err := s.jwt()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx, s.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, apiURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := httpClientOrDefault(s.HTTPClient).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newAPIError(resp, body)
+	}
+	return json.Unmarshal(body, result)
+}
+
+// jwt: a RS256 JSON web token identifying the app, as described in
+// https://docs.github.com/en/developers/apps/authenticating-with-github-apps
+func (s *AppTokenSource) jwt() (string, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		// issued a minute in the past to allow for clock drift
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": strconv.FormatInt(s.AppID, 10),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing jwt for app %d: %w", s.AppID, err)
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func (s *AppTokenSource) baseURL() string {
+	return NormalizeBaseURL(s.BaseURL)
+}
+
+// ParsePrivateKey parses a PEM encoded RSA private key, as github generates them for apps (PKCS#1), or
+// in PKCS#8
+func ParsePrivateKey(pemData []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in private key")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// AppTokenSources keeps one AppTokenSource per app and private key, so installation tokens are reused
+// between reconciles. the zero value is ready to use
+type AppTokenSources struct {
+	// BaseURL is the github api of apps requested without one
+	BaseURL string
+	// HTTPClient and Timeout are given to every AppTokenSource
+	HTTPClient *http.Client
+	Timeout    time.Duration
+
+	mu      sync.Mutex
+	sources map[string]*AppTokenSource
+}
+
+// Get returns the AppTokenSource of the app on the github api at baseURL (empty for the default one),
+// creating it on first use
+func (a *AppTokenSources) Get(baseURL string, appID, installationID int64, privateKeyPEM []byte) (*AppTokenSource, error) {
+	if baseURL == "" {
+		baseURL = a.BaseURL
+	}
+	baseURL = NormalizeBaseURL(baseURL)
+	digest := sha256.Sum256(privateKeyPEM)
+	key := fmt.Sprintf("%s/%d/%d/%s", baseURL, appID, installationID, hex.EncodeToString(digest[:8]))
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if source, ok := a.sources[key]; ok {
+		return source, nil
+	}
+	privateKey, err := ParsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	source := &AppTokenSource{
+		AppID:          appID,
+		InstallationID: installationID,
+		PrivateKey:     privateKey,
+		BaseURL:        baseURL,
+		HTTPClient:     a.HTTPClient,
+		Timeout:        a.Timeout,
+	}
+	if a.sources == nil {
+		a.sources = map[string]*AppTokenSource{}
+	}
+	a.sources[key] = source
+	return source, nil
+}
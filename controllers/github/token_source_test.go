@@ -0,0 +1,259 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAppServer plays github's app endpoints, handing out installation tokens that expire after tokenLifetime
+type fakeAppServer struct {
+	t             *testing.T
+	publicKey     *rsa.PublicKey
+	tokenLifetime time.Duration
+	// installationID is the installation of the app on testUser/testRepo, 42 when it isn't set
+	installationID int64
+	// tokenRequested, when set, gets every token request, which waits for release to be closed
+	tokenRequested chan struct{}
+	release        chan struct{}
+
+	mu           sync.Mutex
+	tokensIssued int
+	lookups      int
+}
+
+func (f *fakeAppServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.verifyJWT(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	f.mu.Lock()
+	installationID := f.installationID
+	if installationID == 0 {
+		installationID = 42
+	}
+	f.mu.Unlock()
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/repos/testUser/testRepo/installation":
+		f.mu.Lock()
+		f.lookups++
+		f.mu.Unlock()
+		_, _ = fmt.Fprintf(w, `{"id":%d}`, installationID)
+	case r.Method == "POST" && r.URL.Path == fmt.Sprintf("/app/installations/%d/access_tokens", installationID):
+		if f.tokenRequested != nil {
+			f.tokenRequested <- struct{}{}
+			<-f.release
+		}
+		f.mu.Lock()
+		f.tokensIssued++
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(installationToken{
+			Token:     fmt.Sprintf("installation-token-%d", f.tokensIssued),
+			ExpiresAt: time.Now().Add(f.tokenLifetime),
+		})
+	case r.Method == "POST" && strings.HasPrefix(r.URL.Path, "/app/installations/"):
+		// an installation that was removed
+		w.WriteHeader(http.StatusNotFound)
+	default:
+		f.t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeAppServer) verifyJWT(jwt string) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		f.t.Fatalf("Expected a JWT but got: %q", jwt)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(f.publicKey, crypto.SHA256, digest[:], signature); err != nil {
+		f.t.Errorf("Expected a JWT signed with the app's key but got: %v", err)
+	}
+	var claims map[string]interface{}
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	_ = json.Unmarshal(payload, &claims)
+	if claims["iss"] != "7" {
+		f.t.Errorf("Expected the app id as issuer but got: %v", claims["iss"])
+	}
+}
+
+func newTestAppTokenSource(t *testing.T, tokenLifetime time.Duration) (*AppTokenSource, *fakeAppServer, func()) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	fake := &fakeAppServer{t: t, publicKey: &privateKey.PublicKey, tokenLifetime: tokenLifetime}
+	server := httptest.NewServer(fake)
+	source := &AppTokenSource{AppID: 7, PrivateKey: privateKey, BaseURL: server.URL + "/"}
+	return source, fake, server.Close
+}
+
+func TestAppTokenSourceCachesInstallationToken(t *testing.T) {
+	//given an app whose installation tokens are valid for an hour
+	source, fake, closeServer := newTestAppTokenSource(t, time.Hour)
+	defer closeServer()
+
+	//when asking for a token twice
+	first, firstErr := source.Token(context.Background(), "testUser/testRepo")
+	second, secondErr := source.Token(context.Background(), "testUser/testRepo")
+
+	//then the installation is looked up and a token is issued only once
+	if firstErr != nil || secondErr != nil {
+		t.Fatalf("Expected no errors but got: %v, %v", firstErr, secondErr)
+	}
+	if first != "installation-token-1" || second != first {
+		t.Errorf("Expected the cached installation token but got: %q, %q", first, second)
+	}
+	if fake.lookups != 1 || fake.tokensIssued != 1 {
+		t.Errorf("Expected one lookup and one token but got: %d lookups, %d tokens", fake.lookups, fake.tokensIssued)
+	}
+}
+
+func TestAppTokenSourceRefreshesBeforeExpiry(t *testing.T) {
+	//given an app whose installation tokens expire within the refresh margin
+	source, fake, closeServer := newTestAppTokenSource(t, time.Minute)
+	defer closeServer()
+
+	//when asking for a token twice
+	first, _ := source.Token(context.Background(), "testUser/testRepo")
+	second, err := source.Token(context.Background(), "testUser/testRepo")
+
+	//then a new token is issued for the second call
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if first == second || fake.tokensIssued != 2 {
+		t.Errorf("Expected a refreshed token but got: %q, %q (%d issued)", first, second, fake.tokensIssued)
+	}
+}
+
+func TestAppTokenSourcesReuseSources(t *testing.T) {
+	//given a PKCS#1 PEM private key
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	sources := AppTokenSources{}
+
+	//when getting the source of the same app twice
+	first, err := sources.Get("", 7, 0, pemKey)
+	second, _ := sources.Get("", 7, 0, pemKey)
+
+	//then the same source (with its cached tokens) is returned
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if first != second {
+		t.Errorf("Expected the same token source to be reused")
+	}
+	if _, err = sources.Get("", 7, 0, []byte("not a key")); err == nil {
+		t.Errorf("Expected an error for an invalid private key")
+	}
+}
+
+func TestAppTokenSourceCreatesOneTokenForConcurrentCalls(t *testing.T) {
+	//given reconciles needing the token of an installation while github is slow to create it
+	source, fake, closeServer := newTestAppTokenSource(t, time.Hour)
+	defer closeServer()
+	fake.tokenRequested = make(chan struct{}, 10)
+	fake.release = make(chan struct{})
+	source.installations = map[string]int64{"testUser/otherRepo": 43}
+	source.tokens = map[int64]installationToken{43: {Token: "other", ExpiresAt: time.Now().Add(time.Hour)}}
+	results := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			token, err := source.Token(context.Background(), "testUser/testRepo")
+			if err != nil {
+				t.Errorf("Expected a token but got: %v", err)
+			}
+			results <- token
+		}()
+	}
+
+	//when the token is created
+	<-fake.tokenRequested
+	// the cached token of another installation is served meanwhile, the lock isn't held while github is called
+	cached := make(chan string, 1)
+	go func() {
+		token, _ := source.Token(context.Background(), "testUser/otherRepo")
+		cached <- token
+	}()
+	select {
+	case token := <-cached:
+		if token != "other" {
+			t.Errorf("Expected the cached token but got: %s", token)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the cached token to be served while another is created")
+	}
+	close(fake.release)
+
+	//then every call gets the single token created
+	for i := 0; i < 3; i++ {
+		if token := <-results; token != "installation-token-1" {
+			t.Errorf("Expected the created token but got: %s", token)
+		}
+	}
+	if fake.tokensIssued != 1 {
+		t.Errorf("Expected a single token to be created but got: %d", fake.tokensIssued)
+	}
+}
+
+func TestAppTokenSourceForgetsRemovedInstallation(t *testing.T) {
+	//given an app whose installation on the repository was looked up, then removed and installed again
+	source, fake, closeServer := newTestAppTokenSource(t, time.Hour)
+	defer closeServer()
+	source.installations = map[string]int64{"testUser/testRepo": 42}
+	fake.installationID = 43
+
+	//when creating a token fails for the removed installation, and is retried
+	if _, err := source.Token(context.Background(), "testUser/testRepo"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Expected the removed installation not to be found but got: %v", err)
+	}
+	token, err := source.Token(context.Background(), "testUser/testRepo")
+
+	//then the new installation is looked up and its token created
+	if err != nil {
+		t.Fatalf("Expected a token but got: %v", err)
+	}
+	if token != "installation-token-1" || fake.lookups != 1 {
+		t.Errorf("Expected a token of the new installation after a lookup but got %s after %d", token, fake.lookups)
+	}
+}
+
+func TestRateLimitTrackerFollowsInstallationAcrossTokens(t *testing.T) {
+	//given an installation whose rate limit ran out with its first token
+	source, _, closeServer := newTestAppTokenSource(t, time.Minute)
+	defer closeServer()
+	tracker := NewRateLimitTracker(0)
+	first, _ := source.Token(context.Background(), "testUser/testRepo")
+	resp := &http.Response{Header: http.Header{}}
+	resp.Header.Set("X-RateLimit-Remaining", "0")
+	resp.Header.Set("X-RateLimit-Reset", fmt.Sprint(time.Now().Add(time.Hour).Unix()))
+	tracker.Update(first, "core", resp, nil)
+
+	//when the token is replaced (it expires within the refresh margin)
+	second, _ := source.Token(context.Background(), "testUser/testRepo")
+
+	//then the new token is held back all the same, github meters the installation
+	if second == first {
+		t.Fatalf("Expected a new token but got the same: %s", second)
+	}
+	if err := tracker.Check(second, "core"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected the new token to be rate limited but got: %v", err)
+	}
+	if len(tracker.limits) != 1 {
+		t.Errorf("Expected a single credential to be tracked but got: %d", len(tracker.limits))
+	}
+}
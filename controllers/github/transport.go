@@ -0,0 +1,69 @@
+package github
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultRequestTimeout bounds every request to github when no timeout is configured, so that a hung call
+// can't block a reconcile forever
+const DefaultRequestTimeout = 30 * time.Second
+
+// TransportOptions configure how the operator connects to github
+type TransportOptions struct {
+	// ProxyURL every request goes through. when it's empty the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment
+	// variables are used
+	ProxyURL string
+	// CAFile is a PEM bundle of certificate authorities trusted on top of the system ones, e.g. the CA of a
+	// github enterprise server
+	CAFile string
+}
+
+// NewTransport returns a transport like http.DefaultTransport with the proxy and the CAs of the options
+func NewTransport(opts TransportOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.ProxyURL != "" {
+		proxyURL, err := url.Parse(opts.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy url: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if opts.CAFile != "" {
+		pemData, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	return transport, nil
+}
+
+// requestContext : ctx bounded by the timeout, DefaultRequestTimeout when the timeout isn't positive
+func requestContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// httpClientOrDefault : the client, http.DefaultClient when it's nil
+func httpClientOrDefault(client *http.Client) *http.Client {
+	if client == nil {
+		return http.DefaultClient
+	}
+	return client
+}
@@ -0,0 +1,143 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+)
+
+func TestRequestTimesOut(t *testing.T) {
+	//given github hanging on a request longer than the client's timeout
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+	c := &ClientAPI{BaseURL: server.URL, Timeout: 50 * time.Millisecond}
+
+	//when getting an issue
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+	_, err := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+
+	//then the request is given up on once the timeout passes
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a deadline exceeded error but got: %v", err)
+	}
+}
+
+func TestCancelledContextStopsRequest(t *testing.T) {
+	//given a reconcile whose context was already cancelled
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+	c := newTestClientAPI(server)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	//when getting an issue
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+	_, err := c.GetIssue(ctx, spec, "1", StaticToken("token"))
+
+	//then nothing is sent to github
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a cancelled error but got: %v", err)
+	}
+	if requests != 0 {
+		t.Fatalf("Expected no request to reach github but got %d", requests)
+	}
+}
+
+func TestNewTransportTrustsCAFile(t *testing.T) {
+	//given a github enterprise server with a certificate of its own CA, written to a PEM bundle
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(Issue{Title: "on enterprise", IssueNumber: "1"})
+	}))
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err = ioutil.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	//when calling it through a transport trusting the bundle
+	transport, err := NewTransport(TransportOptions{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("Expected the transport to be created but got: %v", err)
+	}
+	c := &ClientAPI{BaseURL: server.URL, HTTPClient: &http.Client{Transport: transport}}
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+	issue, err := c.GetIssue(context.Background(), spec, "1", StaticToken("token"))
+
+	//then the server's certificate is accepted
+	if err != nil {
+		t.Fatalf("Expected the request to succeed but got: %v", err)
+	}
+	if issue.Title != "on enterprise" {
+		t.Fatalf("Expected the issue of the server but got: %+v", issue)
+	}
+}
+
+func TestNewTransportRejectsBadOptions(t *testing.T) {
+	//given a CA file with no certificates in it
+	dir, err := ioutil.TempDir("", "ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err = ioutil.WriteFile(caFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, opts := range map[string]TransportOptions{
+		"empty CA bundle":   {CAFile: caFile},
+		"missing CA bundle": {CAFile: filepath.Join(dir, "missing.pem")},
+		"bad proxy url":     {ProxyURL: "://proxy"},
+	} {
+		//when creating the transport
+		_, err := NewTransport(opts)
+
+		//then it fails
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestNewTransportUsesProxy(t *testing.T) {
+	//given a proxy url
+	transport, err := NewTransport(TransportOptions{ProxyURL: "http://proxy.example.com:3128"})
+	if err != nil {
+		t.Fatalf("Expected the transport to be created but got: %v", err)
+	}
+
+	//when resolving the proxy of a request to github
+	req, _ := http.NewRequest(http.MethodGet, APIBaseURL, nil)
+	proxyURL, err := transport.Proxy(req)
+
+	//then it goes through the proxy
+	if err != nil || proxyURL == nil || proxyURL.Host != "proxy.example.com:3128" {
+		t.Fatalf("Expected the request to go through the proxy but got: %v, %v", proxyURL, err)
+	}
+}
@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+// commentMarkerFormat is the hidden marker ending the body of every comment the operator manages, it holds the
+// name of the comment in the spec
+const commentMarkerFormat = "<!-- githubissue-operator comment: %s -->"
+
+var commentMarker = regexp.MustCompile(`<!-- githubissue-operator comment: ([a-zA-Z0-9_.-]+) -->\s*$`)
+
+// deletionCommentMarker ends the body of the comment posted when the object is deleted with the CloseWithComment
+// policy, so that a retried deletion doesn't post it twice
+const deletionCommentMarker = "<!-- githubissue-operator deletion comment -->"
+
+//commentBody: the body posted on github for a comment of the spec
+func commentBody(comment examplev1alpha1.IssueComment) string {
+	return comment.Body + "\n\n" + fmt.Sprintf(commentMarkerFormat, comment.Name)
+}
+
+//managedCommentName: the name in the marker of a comment the operator posted, false for comments of people
+func managedCommentName(body string) (string, bool) {
+	match := commentMarker.FindStringSubmatch(body)
+	if match == nil {
+		return "", false
+	}
+	return match[1], true
+}
+
+//issueComments: the comments of the issue, listed unless the client fetched every one of them with the issue
+func (r *GitHubIssueReconciler) issueComments(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue, issue *github.Issue,
+	tokenSource github.TokenSource) ([]*github.Comment, error) {
+	if issue.FetchedComments != nil && len(issue.FetchedComments) >= issue.Comments {
+		return issue.FetchedComments, nil
+	}
+	return r.GithubClient.ListComments(ctx, trackedSpec(ghIssue), string(issue.IssueNumber), tokenSource)
+}
+
+//hasDeletionComment: whether the deletion comment was already posted on the issue
+func (r *GitHubIssueReconciler) hasDeletionComment(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue,
+	issue *github.Issue, tokenSource github.TokenSource) (bool, error) {
+	comments, err := r.issueComments(ctx, ghIssue, issue, tokenSource)
+	if err != nil {
+		return false, err
+	}
+	for _, comment := range comments {
+		if strings.HasSuffix(strings.TrimSpace(comment.Body), deletionCommentMarker) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+//syncComments: create, edit and delete the managed comments of the issue to match the spec, and return them
+//as posted. the issue's comments are only listed when the object manages (or used to manage) comments, and
+//not at all when the client fetched every one of them with the issue
+func (r *GitHubIssueReconciler) syncComments(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue, issue *github.Issue,
+	tokenSource github.TokenSource) ([]examplev1alpha1.CommentStatus, error) {
+	if len(ghIssue.Spec.Comments) == 0 && len(ghIssue.Status.Comments) == 0 {
+		return nil, nil
+	}
+	issueNumber := string(issue.IssueNumber)
+	comments, err := r.issueComments(ctx, ghIssue, issue, tokenSource)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first comment with a name is the managed one, a duplicate (e.g. from a create whose status was lost)
+	// is deleted along with the comments removed from the spec
+	managed := map[string]*github.Comment{}
+	var stale []*github.Comment
+	for _, comment := range comments {
+		if name, ok := managedCommentName(comment.Body); ok {
+			if _, duplicate := managed[name]; duplicate {
+				stale = append(stale, comment)
+			} else {
+				managed[name] = comment
+			}
+		}
+	}
+
+	var statuses []examplev1alpha1.CommentStatus
+	desired := map[string]bool{}
+	for _, specComment := range ghIssue.Spec.Comments {
+		desired[specComment.Name] = true
+		body := commentBody(specComment)
+		comment, exists := managed[specComment.Name]
+		switch {
+		case !exists:
+			if comment, err = r.GithubClient.CreateComment(ctx, ghIssue.Spec, issueNumber, body, tokenSource); err != nil {
+				return nil, err
+			}
+			r.recordIssueEvent(ghIssue, EventReasonCommentCreated, issue, fmt.Sprintf("posted comment %s on issue", specComment.Name))
+		case comment.Body != body:
+			if comment, err = r.GithubClient.EditComment(ctx, ghIssue.Spec, comment, body, tokenSource); err != nil {
+				return nil, err
+			}
+			r.recordIssueEvent(ghIssue, EventReasonCommentEdited, issue, fmt.Sprintf("edited comment %s of issue", specComment.Name))
+		}
+		statuses = append(statuses, examplev1alpha1.CommentStatus{Name: specComment.Name, ID: comment.ID})
+	}
+	for _, comment := range comments {
+		if name, ok := managedCommentName(comment.Body); ok && !desired[name] && managed[name] == comment {
+			stale = append(stale, comment)
+		}
+	}
+	for _, comment := range stale {
+		if err = r.GithubClient.DeleteComment(ctx, ghIssue.Spec, comment, tokenSource); err != nil {
+			return nil, err
+		}
+		name, _ := managedCommentName(comment.Body)
+		r.recordIssueEvent(ghIssue, EventReasonCommentDeleted, issue, fmt.Sprintf("deleted comment %s of issue", name))
+	}
+	return statuses, nil
+}
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+func TestManagedCommentName(t *testing.T) {
+	body := commentBody(examplev1alpha1.IssueComment{Name: "ci-status", Body: "build passed"})
+	if name, ok := managedCommentName(body); !ok || name != "ci-status" {
+		t.Errorf("Expected the comment name in the marker but got: %q, %v", name, ok)
+	}
+	if _, ok := managedCommentName("LGTM <!-- githubissue-operator comment: ci-status --> but not at the end"); ok {
+		t.Errorf("Expected a marker quoted in the middle of a comment to be ignored")
+	}
+}
+
+func TestCommentsAreSynced(t *testing.T) {
+	//given an issue with a human comment, a managed comment that is outdated and one that was removed from the spec
+	issue := createFakeGithubIssue()
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	spec := examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo"}
+	token := github.StaticToken("token")
+	human, _ := fakeGithubClient.CreateComment(context.Background(), spec, "1", "I can reproduce this", token)
+	outdated, _ := fakeGithubClient.CreateComment(context.Background(), spec, "1",
+		commentBody(examplev1alpha1.IssueComment{Name: "ci-status", Body: "build running"}), token)
+	_, _ = fakeGithubClient.CreateComment(context.Background(), spec, "1",
+		commentBody(examplev1alpha1.IssueComment{Name: "old", Body: "no longer wanted"}), token)
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "open", "", []string{FinalizerName}, false)
+	ghIssueObj.Status.IssueNumber = 1
+	ghIssueObj.Spec.Comments = []examplev1alpha1.IssueComment{
+		{Name: "ci-status", Body: "build passed"},
+		{Name: "owner", Body: "owned by team-a"},
+	}
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the managed comments match the spec and the human comment is untouched
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	comments := fakeGithubClient.Comments["1"]
+	if len(comments) != 3 {
+		t.Fatalf("Expected 3 comments but got: %d", len(comments))
+	}
+	if comments[0] != human || human.Body != "I can reproduce this" {
+		t.Errorf("Expected the human comment to be untouched but got: %q", comments[0].Body)
+	}
+	if outdated.Body != commentBody(examplev1alpha1.IssueComment{Name: "ci-status", Body: "build passed"}) {
+		t.Errorf("Expected the outdated comment to be edited but got: %q", outdated.Body)
+	}
+	if name, _ := managedCommentName(comments[2].Body); name != "owner" {
+		t.Errorf("Expected the new comment to be posted but got: %q", comments[2].Body)
+	}
+
+	updated := getGithubIssueObject(t, fakeK8sClient)
+	expected := []examplev1alpha1.CommentStatus{{Name: "ci-status", ID: outdated.ID}, {Name: "owner", ID: comments[2].ID}}
+	if len(updated.Status.Comments) != 2 || updated.Status.Comments[0] != expected[0] ||
+		updated.Status.Comments[1] != expected[1] {
+		t.Errorf("Expected status comments %v but got: %v", expected, updated.Status.Comments)
+	}
+}
+
+func TestCommentsRemovedFromSpecAreDeleted(t *testing.T) {
+	//given an object whose last managed comment was removed from the spec
+	issue := createFakeGithubIssue()
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	comment, _ := fakeGithubClient.CreateComment(context.Background(), examplev1alpha1.GitHubIssueSpec{}, "1",
+		commentBody(examplev1alpha1.IssueComment{Name: "ci-status", Body: "build passed"}), github.StaticToken("token"))
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "open", "", []string{FinalizerName}, false)
+	ghIssueObj.Status.IssueNumber = 1
+	ghIssueObj.Status.Comments = []examplev1alpha1.CommentStatus{{Name: "ci-status", ID: comment.ID}}
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the comment is deleted and no longer reported
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if len(fakeGithubClient.Comments["1"]) != 0 {
+		t.Errorf("Expected the comment to be deleted but got: %d comments", len(fakeGithubClient.Comments["1"]))
+	}
+	if updated := getGithubIssueObject(t, fakeK8sClient); len(updated.Status.Comments) != 0 {
+		t.Errorf("Expected no comments in the status but got: %v", updated.Status.Comments)
+	}
+}
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+// reasons of the GitHubIssue conditions
+const (
+	ReasonReconciled         = "Reconciled"
+	ReasonCredentialsValid   = "CredentialsAccepted"
+	ReasonInvalidCredentials = "InvalidCredentials"
+	ReasonUnauthorized       = "Unauthorized"
+	ReasonRateLimited        = "RateLimited"
+	ReasonNotRateLimited     = "WithinRateLimit"
+	ReasonIssueNotFound      = "IssueNotFound"
+	ReasonGithubError        = "GithubError"
+	ReasonIssueOwned         = "IssueOwnedByAnotherObject"
+)
+
+// credentialsError is an error reading the credentials of an object, before github is called
+type credentialsError struct {
+	err error
+}
+
+func (e *credentialsError) Error() string {
+	return e.err.Error()
+}
+
+func (e *credentialsError) Unwrap() error {
+	return e.err
+}
+
+//setCondition: set a condition of the object for its current generation
+func setCondition(ghIssue *examplev1alpha1.GitHubIssue, conditionType string, status metav1.ConditionStatus,
+	reason, message string) {
+	meta.SetStatusCondition(&ghIssue.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		ObservedGeneration: ghIssue.Generation,
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+//markSynced: set the conditions of an object whose github issue matches its spec
+func markSynced(ghIssue *examplev1alpha1.GitHubIssue) {
+	ghIssue.Status.ObservedGeneration = ghIssue.Generation
+	ghIssue.Status.SyncedGeneration = ghIssue.Generation
+	setCondition(ghIssue, examplev1alpha1.ConditionReady, metav1.ConditionTrue, ReasonReconciled,
+		"the github issue matches the spec")
+	setCondition(ghIssue, examplev1alpha1.ConditionSynced, metav1.ConditionTrue, ReasonReconciled,
+		"the github issue was reconciled successfully")
+	setCondition(ghIssue, examplev1alpha1.ConditionCredentialsValid, metav1.ConditionTrue, ReasonCredentialsValid,
+		"github accepted the credentials")
+	setCondition(ghIssue, examplev1alpha1.ConditionRateLimited, metav1.ConditionFalse, ReasonNotRateLimited,
+		"the credentials are within github's rate limit")
+}
+
+//failureReason: the reason reported for a reconcile that failed with err
+func failureReason(err error) string {
+	var credentialsErr *credentialsError
+	var ownershipErr *ownershipError
+	switch {
+	case errors.As(err, &credentialsErr):
+		return ReasonInvalidCredentials
+	case errors.As(err, &ownershipErr):
+		return ReasonIssueOwned
+	case errors.Is(err, github.ErrUnauthorized):
+		return ReasonUnauthorized
+	case errors.Is(err, github.ErrRateLimited):
+		return ReasonRateLimited
+	case errors.Is(err, github.ErrNotFound):
+		return ReasonIssueNotFound
+	}
+	return ReasonGithubError
+}
+
+//markFailed: set the conditions of an object whose reconcile failed with err
+func markFailed(ghIssue *examplev1alpha1.GitHubIssue, err error) {
+	ghIssue.Status.ObservedGeneration = ghIssue.Generation
+	message := err.Error()
+	reason := failureReason(err)
+	switch reason {
+	case ReasonInvalidCredentials, ReasonUnauthorized:
+		setCondition(ghIssue, examplev1alpha1.ConditionCredentialsValid, metav1.ConditionFalse, reason, message)
+	case ReasonRateLimited:
+		setCondition(ghIssue, examplev1alpha1.ConditionRateLimited, metav1.ConditionTrue, reason, message)
+	}
+	if reason != ReasonRateLimited {
+		setCondition(ghIssue, examplev1alpha1.ConditionRateLimited, metav1.ConditionFalse, ReasonNotRateLimited,
+			"the credentials are within github's rate limit")
+	}
+	setCondition(ghIssue, examplev1alpha1.ConditionReady, metav1.ConditionFalse, reason, message)
+	setCondition(ghIssue, examplev1alpha1.ConditionSynced, metav1.ConditionFalse, reason, message)
+}
+
+//recordFailure: patch the conditions of an object whose reconcile failed with err and record a warning event.
+//the reconcile error is what matters to the caller, so a failing patch is only logged
+func (r *GitHubIssueReconciler) recordFailure(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue, err error) {
+	r.recordWarning(ghIssue, failureReason(err), err)
+	patch := client.MergeFrom(ghIssue.DeepCopy())
+	markFailed(&ghIssue, err)
+	if patchErr := r.Client.Status().Patch(ctx, &ghIssue, patch); patchErr != nil {
+		r.Log.Error(patchErr, "unable to record the failure in the status", "githubissue", ghIssue.Name)
+	}
+}
@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+func getGithubIssueObject(t *testing.T, fakeK8sClient client.Client) examplev1alpha1.GitHubIssue {
+	ghIssue := examplev1alpha1.GitHubIssue{}
+	if err := fakeK8sClient.Get(context.Background(), createReq().NamespacedName, &ghIssue); err != nil {
+		t.Fatalf("Expected to get the object but got an error: %v", err)
+	}
+	return ghIssue
+}
+
+func expectCondition(t *testing.T, ghIssue examplev1alpha1.GitHubIssue, conditionType string,
+	status metav1.ConditionStatus, reason string) {
+	condition := meta.FindStatusCondition(ghIssue.Status.Conditions, conditionType)
+	if condition == nil {
+		t.Errorf("Expected condition %s but it isn't set", conditionType)
+		return
+	}
+	if condition.Status != status || condition.Reason != reason {
+		t.Errorf("Expected condition %s to be %s (%s) but got: %s (%s)", conditionType, status, reason,
+			condition.Status, condition.Reason)
+	}
+	if condition.ObservedGeneration != ghIssue.Generation {
+		t.Errorf("Expected condition %s for generation %d but got: %d", conditionType, ghIssue.Generation,
+			condition.ObservedGeneration)
+	}
+}
+
+func TestSuccessfulReconcileSetsReady(t *testing.T) {
+	//given an empty repository and a valid ghIssue object of generation 3
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{}, false)
+	ghIssueObj.Generation = 3
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the object is ready for its generation
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	updated := getGithubIssueObject(t, fakeK8sClient)
+	if updated.Status.ObservedGeneration != 3 {
+		t.Errorf("Expected observed generation 3 but got: %d", updated.Status.ObservedGeneration)
+	}
+	expectCondition(t, updated, examplev1alpha1.ConditionReady, metav1.ConditionTrue, ReasonReconciled)
+	expectCondition(t, updated, examplev1alpha1.ConditionSynced, metav1.ConditionTrue, ReasonReconciled)
+	expectCondition(t, updated, examplev1alpha1.ConditionCredentialsValid, metav1.ConditionTrue, ReasonCredentialsValid)
+	expectCondition(t, updated, examplev1alpha1.ConditionRateLimited, metav1.ConditionFalse, ReasonNotRateLimited)
+}
+
+func TestFailedCreateSetsNotSynced(t *testing.T) {
+	//given a fake github client that always fails on create
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, true, github.CreatError)
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the failure shows in the conditions
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	updated := getGithubIssueObject(t, fakeK8sClient)
+	expectCondition(t, updated, examplev1alpha1.ConditionReady, metav1.ConditionFalse, ReasonGithubError)
+	expectCondition(t, updated, examplev1alpha1.ConditionSynced, metav1.ConditionFalse, ReasonGithubError)
+}
+
+func TestRateLimitSetsRateLimited(t *testing.T) {
+	//given a github client that is rate limited
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	fakeGithubClient.RateLimitReset = time.Now().Add(time.Minute)
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, _ = r.Reconcile(context.Background(), createReq())
+
+	//then the object is rate limited and not ready
+	updated := getGithubIssueObject(t, fakeK8sClient)
+	expectCondition(t, updated, examplev1alpha1.ConditionRateLimited, metav1.ConditionTrue, ReasonRateLimited)
+	expectCondition(t, updated, examplev1alpha1.ConditionReady, metav1.ConditionFalse, ReasonRateLimited)
+}
+
+func TestMissingSecretSetsCredentialsInvalid(t *testing.T) {
+	//given an object referencing a secret that doesn't exist
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+	ghIssueObj.Spec.TokenSecretRef = &examplev1alpha1.SecretKeyReference{Name: "missing"}
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, _ = r.Reconcile(context.Background(), createReq())
+
+	//then the credentials are reported invalid
+	updated := getGithubIssueObject(t, fakeK8sClient)
+	expectCondition(t, updated, examplev1alpha1.ConditionCredentialsValid, metav1.ConditionFalse, ReasonInvalidCredentials)
+	expectCondition(t, updated, examplev1alpha1.ConditionReady, metav1.ConditionFalse, ReasonInvalidCredentials)
+}
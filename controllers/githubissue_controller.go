@@ -17,14 +17,22 @@ import (
 	"context"
 	errors2 "github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/api/errors"
-	"os"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
-	//"strconv"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
 	//imports for the create function
@@ -33,7 +41,10 @@ import (
 )
 
 const FinalizerName = "example.training.redhat.com/finalizer"
-const TitleNotFound = "object title not found on github"
+
+// DefaultResyncInterval is how often issues are compared against github when neither the object nor the
+// operator's flag set an interval
+const DefaultResyncInterval = 10 * time.Minute
 
 // GitHubIssueReconciler reconciles a GitHubIssue object
 type GitHubIssueReconciler struct {
@@ -41,11 +52,19 @@ type GitHubIssueReconciler struct {
 	Log          logr.Logger
 	Scheme       *runtime.Scheme
 	GithubClient github.Client
+	// AppTokens caches the token sources of objects authenticating as a github app
+	AppTokens *github.AppTokenSources
+	Recorder  record.EventRecorder
+	// ResyncInterval is how often issues are compared against github, objects may override it
+	ResyncInterval time.Duration
+	// WebhookEvents, when set, carries the objects whose issue changed on github (see WebhookReceiver)
+	WebhookEvents <-chan event.GenericEvent
 }
 
 //+kubebuilder:rbac:groups=example.training.redhat.com,resources=githubissues,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=example.training.redhat.com,resources=githubissues/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=example.training.redhat.com,resources=githubissues/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -75,11 +94,46 @@ func (r *GitHubIssueReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	}
 	//println("here2")
 
+	// an orphaned issue is left as it is on github, so the object goes without calling github: neither missing
+	// credentials nor github failing hold it back
+	if !ghIssue.ObjectMeta.DeletionTimestamp.IsZero() && ghIssue.Spec.DeletionPolicy == examplev1alpha1.DeletionPolicyOrphan {
+		if err = r.removeFinalizer(ctx, ghIssue); err != nil {
+			return ctrl.Result{}, errors2.Wrap(err, "error during removeFinalizer")
+		}
+		return ctrl.Result{}, nil
+	}
+
 	//bring the issue from the real world (if doesn't exists return nil and err)
-	token := os.Getenv("GITHUB_TOKEN")
-	issue, findIssueErr := r.GithubClient.FindIssue(ghIssue.Spec, token)
-	if findIssueErr != nil && fmt.Sprintf("%v", findIssueErr) != TitleNotFound {
-		return ctrl.Result{}, errors2.Wrap(findIssueErr, "error during findIssue")
+	tokenSource, err := r.resolveTokenSource(ctx, ghIssue)
+	if err != nil {
+		// the secret is often deleted before the object when their namespace is torn down, retrying won't bring
+		// it back. the object waits for the secret to be restored (which reconciles it) or to be orphaned
+		if !ghIssue.ObjectMeta.DeletionTimestamp.IsZero() && errors.IsNotFound(err) {
+			r.recordFailure(ctx, ghIssue, &credentialsError{fmt.Errorf(
+				"%w: restore it, or set spec.deletionPolicy to Orphan to delete the object without closing its issue", err)})
+			return ctrl.Result{}, nil
+		}
+		r.recordFailure(ctx, ghIssue, &credentialsError{err})
+		return ctrl.Result{}, errors2.Wrap(err, "error during resolveTokenSource")
+	}
+	// a change of spec.repo moves the tracked issue before anything else is reconciled
+	if repoChanged(ghIssue) && ghIssue.ObjectMeta.DeletionTimestamp.IsZero() {
+		return r.moveIssue(ctx, ghIssue, tokenSource)
+	}
+	issue, findIssueErr := r.fetchIssue(ctx, ghIssue, tokenSource)
+	if findIssueErr != nil && !errors2.Is(findIssueErr, github.ErrNotFound) {
+		return r.handleGithubError(ctx, ghIssue, findIssueErr, "error during findIssue")
+	}
+	if findIssueErr == nil {
+		if manage, err := r.checkOwnership(ctx, ghIssue, issue); err != nil || !manage {
+			// another object's issue (or, by the adoption policy, a stranger's) is left alone. a deleted object
+			// just drops its finalizer, unless the owner couldn't be looked up
+			var ownershipErr *ownershipError
+			if err != nil && (ghIssue.ObjectMeta.DeletionTimestamp.IsZero() || !errors2.As(err, &ownershipErr)) {
+				return r.handleGithubError(ctx, ghIssue, err, "error during checkOwnership")
+			}
+			issue, findIssueErr = nil, github.ErrTitleNotFound
+		}
 	}
 	log.Info("find issue is ok")
 	//println("here3")
@@ -94,45 +148,86 @@ func (r *GitHubIssueReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		}
 	} else {
 		// The object is being deleted
-		err := r.deleteGithubIssueObject(ghIssue, issue, findIssueErr, ctx, token)
-		return ctrl.Result{}, errors2.Wrap(err, "error during deleteGithubIssueObject")
+		if err := r.deleteGithubIssueObject(ghIssue, issue, findIssueErr, ctx, tokenSource); err != nil {
+			return r.handleGithubError(ctx, ghIssue, err, "error during deleteGithubIssueObject")
+		}
+		return ctrl.Result{}, nil
 	}
 	//println("here4")
+	// the spec as sent to github, stamped with the object's ownership marker
+	desired := desiredSpec(ghIssue)
+
 	// if issue wasn't found (according to title) on github, create it
-	if fmt.Sprintf("%v", findIssueErr) == TitleNotFound {
-		if issue, err = r.GithubClient.Create(ghIssue.Spec, token); err != nil {
-			return ctrl.Result{}, errors2.Wrap(err, "error during create")
+	if errors2.Is(findIssueErr, github.ErrTitleNotFound) {
+		if issue, err = r.createIssue(ctx, ghIssue, desired, tokenSource); err != nil {
+			return r.handleGithubError(ctx, ghIssue, err, "error during create")
+		}
+	} else if findIssueErr == nil && ghIssue.Status.IssueNumber == 0 {
+		// found by title. an issue with the object's own marker is one it created before losing track of it,
+		// any other is an issue opened before the object that it now manages
+		if owner, marked := ownerOf(issue); marked && owner.UID == string(ghIssue.UID) {
+			log.Info("found the issue created by an earlier reconcile", "issue number", string(issue.IssueNumber))
 		} else {
-			log.Info("created successfully", "issue number", string(issue.IssueNumber))
+			r.recordIssueEvent(ghIssue, EventReasonAdopted, issue, "adopted existing issue")
 		}
+	} else if findIssueErr != nil {
+		// the issue we track by number is gone, don't open a new one behind the user's back
+		return r.handleGithubError(ctx, ghIssue, findIssueErr,
+			fmt.Sprintf("issue number %d in status no longer exists on github", ghIssue.Status.IssueNumber))
 	}
 
-	// edit description if needed
-	if ghIssue.Spec.Description != issue.Description {
-		//edit description only if there's a difference OR issue was closed
-		if err = r.GithubClient.Edit(ghIssue.Spec, string(issue.IssueNumber), token); err != nil {
+	// edit the issue if any managed field drifted from the spec (e.g. the title was renamed on github)
+	if drifted := driftedFields(desired, issue); len(drifted) > 0 {
+		// the spec didn't change since the last successful sync, so it's github that changed. a failed reconcile
+		// observes the generation without syncing it, and doesn't count
+		if ghIssue.Status.IssueNumber != 0 && ghIssue.Status.SyncedGeneration == ghIssue.Generation {
+			r.recordDrift(ghIssue, issue, drifted)
+		}
+		previousState := issue.State
+		if issue, err = r.GithubClient.Edit(ctx, desired, string(issue.IssueNumber), tokenSource); err != nil {
 			log.Info("problem here!!!")
-			return ctrl.Result{}, errors2.Wrap(err, "error during edit")
+			return r.handleGithubError(ctx, ghIssue, err, "error during edit")
 		}
-		log.Info("edited successfully", "issue number", string(issue.IssueNumber))
+		log.Info("edited successfully", "issue number", string(issue.IssueNumber), "drifted fields", drifted)
+		issuesEditedTotal.Inc()
+		if issue.State == "closed" && previousState != "closed" {
+			issuesClosedTotal.Inc()
+		}
+		r.recordEdit(ghIssue, issue, previousState, drifted)
+	}
+
+	comments, err := r.syncComments(ctx, ghIssue, issue, tokenSource)
+	if err != nil {
+		return r.handleGithubError(ctx, ghIssue, err, "error during syncComments")
 	}
 
 	// update status fields
-	if err = r.updateStatus(ghIssue, issue, ctx); err != nil {
+	if err = r.updateStatus(ghIssue, issue, comments, ctx); err != nil {
 		return ctrl.Result{}, errors2.Wrap(err, "error during updateStatus")
 	}
 
-	fmt.Printf("title: %s \ndescription: %s\nstatus is: %s \n", ghIssue.Spec.Title, ghIssue.Spec.Description, ghIssue.Status.State)
-	fmt.Printf("last updated at: %s \n", ghIssue.Status.LastUpdateTimestamp)
+	log.V(1).Info("reconciled", "title", issue.Title, "state", issue.State, "last updated at", issue.LastUpdateTimestamp)
 
-	return ctrl.Result{}, nil
+	// come back later to catch changes made on github, which trigger no kubernetes event
+	return ctrl.Result{RequeueAfter: r.resyncInterval(ghIssue)}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *GitHubIssueReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &examplev1alpha1.GitHubIssue{},
+		IssueIndexField, indexIssueKey); err != nil {
+		return err
+	}
+	if err := metrics.Registry.Register(&managedIssuesCollector{client: mgr.GetClient(), log: r.Log}); err != nil {
+		return err
+	}
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&examplev1alpha1.GitHubIssue{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.findObjectsForSecret))
+	if r.WebhookEvents != nil {
+		builder = builder.Watches(&source.Channel{Source: r.WebhookEvents}, &handler.EnqueueRequestForObject{})
+	}
+	return builder.Complete(r)
 }
 
 func (r *GitHubIssueReconciler) registerFinalizer(ghIssue examplev1alpha1.GitHubIssue, ctx context.Context) error {
@@ -141,22 +236,30 @@ func (r *GitHubIssueReconciler) registerFinalizer(ghIssue examplev1alpha1.GitHub
 	return err
 }
 
+//removeFinalizer: let the object go, if our finalizer still holds it
+func (r *GitHubIssueReconciler) removeFinalizer(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue) error {
+	if !containsString(ghIssue.GetFinalizers(), FinalizerName) {
+		return nil
+	}
+	controllerutil.RemoveFinalizer(&ghIssue, FinalizerName)
+	return r.Update(ctx, &ghIssue)
+}
+
 //deleteGithubIssueObject: delete the object, it finalizer exists - handle it and then delete object
 func (r *GitHubIssueReconciler) deleteGithubIssueObject(ghIssue examplev1alpha1.GitHubIssue, realWorldIssue *github.Issue,
-	findIssueErr error, ctx context.Context, token string) error {
+	findIssueErr error, ctx context.Context, tokenSource github.TokenSource) error {
 	if containsString(ghIssue.GetFinalizers(), FinalizerName) {
 		// our finalizer is present, so lets handle any external dependency
 		// if the issue isn't on github, skip the external handle and just remove finalizer
-		if fmt.Sprintf("%v", findIssueErr) != TitleNotFound {
-			if err := r.GithubClient.Close(ghIssue.Spec, string(realWorldIssue.IssueNumber), token); err != nil {
+		if !errors2.Is(findIssueErr, github.ErrNotFound) {
+			if err := r.closeGithubIssue(ctx, ghIssue, realWorldIssue, tokenSource); err != nil {
 				// if fail to delete the external dependency here, return with error
 				// so that it can be retried
 				return err
 			}
 		}
 		// remove our finalizer from the list and update it.
-		controllerutil.RemoveFinalizer(&ghIssue, FinalizerName)
-		if err := r.Update(ctx, &ghIssue); err != nil {
+		if err := r.removeFinalizer(ctx, ghIssue); err != nil {
 			return err
 		}
 	}
@@ -165,8 +268,164 @@ func (r *GitHubIssueReconciler) deleteGithubIssueObject(ghIssue examplev1alpha1.
 
 }
 
+//closeGithubIssue: close the github issue of a deleted object, commenting on it first for the CloseWithComment
+//deletion policy. a comment posted by an earlier attempt whose close failed isn't posted again
+func (r *GitHubIssueReconciler) closeGithubIssue(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue, realWorldIssue *github.Issue,
+	tokenSource github.TokenSource) error {
+	issueNumber := string(realWorldIssue.IssueNumber)
+	// an issue that is already closed (e.g. by hand) gets no comment
+	if ghIssue.Spec.DeletionPolicy == examplev1alpha1.DeletionPolicyCloseWithComment && realWorldIssue.State != "closed" {
+		comment := ghIssue.Spec.DeletionComment
+		if comment == "" {
+			comment = fmt.Sprintf("Closing this issue because the GitHubIssue %s/%s managing it was deleted.",
+				ghIssue.Namespace, ghIssue.Name)
+		}
+		posted, err := r.hasDeletionComment(ctx, ghIssue, realWorldIssue, tokenSource)
+		if err != nil {
+			return err
+		}
+		if !posted {
+			if _, err = r.GithubClient.CreateComment(ctx, trackedSpec(ghIssue), issueNumber,
+				comment+"\n\n"+deletionCommentMarker, tokenSource); err != nil {
+				return err
+			}
+		}
+	}
+	// the body is sent along, keep the ownership marker in it
+	closed := desiredSpec(ghIssue)
+	closed.Repo = trackedRepo(ghIssue)
+	if err := r.GithubClient.Close(ctx, closed, issueNumber, tokenSource); err != nil {
+		return err
+	}
+	issuesClosedTotal.Inc()
+	r.recordIssueEvent(ghIssue, EventReasonClosed, realWorldIssue, "closed issue of the deleted object")
+	return nil
+}
+
+//createIssue: open the issue of the object, unless an earlier reconcile already did and failed before recording
+//it in the status (e.g. the response was lost). such an issue is found by the ownership marker of the object,
+//which holds its uid. the number of a created issue is persisted right away in a patch of its own, so that a
+//failure later in the reconcile doesn't lose track of it
+func (r *GitHubIssueReconciler) createIssue(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue,
+	desired examplev1alpha1.GitHubIssueSpec, tokenSource github.TokenSource) (*github.Issue, error) {
+	// the issue can't have been created before the object was
+	issue, err := r.GithubClient.FindIssueByMarker(ctx, ghIssue.Spec, ownerMarkerOf(ghIssue),
+		ghIssue.CreationTimestamp.Time, tokenSource)
+	if err == nil {
+		r.Log.Info("found the issue created by an earlier reconcile", "githubissue", ghIssue.Name,
+			"issue number", string(issue.IssueNumber))
+		return issue, nil
+	}
+	if !errors2.Is(err, github.ErrNotFound) {
+		return nil, err
+	}
+	if issue, err = r.GithubClient.Create(ctx, desired, tokenSource); err != nil {
+		return nil, err
+	}
+	r.Log.Info("created successfully", "githubissue", ghIssue.Name, "issue number", string(issue.IssueNumber))
+	issuesCreatedTotal.Inc()
+	r.recordIssueEvent(ghIssue, EventReasonCreated, issue, "created issue")
+	if err = r.recordIssueNumber(ctx, ghIssue, issue); err != nil {
+		return nil, errors2.Wrap(err, "error during recordIssueNumber")
+	}
+	return issue, nil
+}
+
+//recordIssueNumber: set the number of the issue in the status of the object, and nothing else
+func (r *GitHubIssueReconciler) recordIssueNumber(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue,
+	issue *github.Issue) error {
+	patch := client.MergeFrom(ghIssue.DeepCopy())
+	if issueNumber, err := issue.IssueNumber.Int64(); err == nil {
+		ghIssue.Status.IssueNumber = int(issueNumber)
+	}
+	ghIssue.Status.NodeID = issue.NodeID
+	ghIssue.Status.Repo = ghIssue.Spec.Repo
+	return r.Client.Status().Patch(ctx, &ghIssue, patch)
+}
+
+//handleGithubError: record the failure in the object's conditions. when github rate limits us, requeue once the
+//limit resets instead of returning the error (which would retry with the controller's backoff and keep hitting
+//the limit); otherwise wrap the error
+func (r *GitHubIssueReconciler) handleGithubError(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue, err error,
+	message string) (ctrl.Result, error) {
+	r.recordFailure(ctx, ghIssue, err)
+	if wait, limited := github.RateLimitWait(err); limited {
+		r.Log.Info("github rate limit exceeded, requeueing", "requeueAfter", wait.String(), "error", err.Error())
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	return ctrl.Result{}, errors2.Wrap(err, message)
+}
+
+//fetchIssue: bring the github issue of the object. once the issue number is recorded in the status the issue
+//is fetched by it, searching by title is only used to adopt an existing issue the first time
+func (r *GitHubIssueReconciler) fetchIssue(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue,
+	tokenSource github.TokenSource) (*github.Issue, error) {
+	if ghIssue.Status.IssueNumber != 0 {
+		return r.GithubClient.GetIssue(ctx, trackedSpec(ghIssue), strconv.Itoa(ghIssue.Status.IssueNumber), tokenSource)
+	}
+	return r.GithubClient.FindIssue(ctx, ghIssue.Spec, tokenSource)
+}
+
+//resyncInterval: how long until the object is compared against github again, 0 for never
+func (r *GitHubIssueReconciler) resyncInterval(ghIssue examplev1alpha1.GitHubIssue) time.Duration {
+	if ghIssue.Spec.ResyncInterval != nil {
+		return ghIssue.Spec.ResyncInterval.Duration
+	}
+	return r.ResyncInterval
+}
+
 //deleteExternalResources: close github issue
 
+//driftedFields: names of the managed fields of the spec that differ on the github issue. labels, assignees and
+//milestone are only managed when set in the spec, the state is always managed (open unless the spec says closed),
+//and so is the reason of a closed state when the spec sets one
+func driftedFields(spec examplev1alpha1.GitHubIssueSpec, issue *github.Issue) []string {
+	var drifted []string
+	if spec.Title != issue.Title {
+		drifted = append(drifted, "title")
+	}
+	if spec.Description != issue.Description {
+		drifted = append(drifted, "description")
+	}
+	if len(spec.Labels) > 0 && !sameStringSet(spec.Labels, issue.LabelNames()) {
+		drifted = append(drifted, "labels")
+	}
+	if len(spec.Assignees) > 0 && !sameStringSet(spec.Assignees, issue.AssigneeLogins()) {
+		drifted = append(drifted, "assignees")
+	}
+	if spec.Milestone != nil && *spec.Milestone != issue.MilestoneNumber() {
+		drifted = append(drifted, "milestone")
+	}
+	if github.DesiredState(spec) != issue.State {
+		drifted = append(drifted, "state")
+	} else if issue.State == string(examplev1alpha1.IssueStateClosed) && spec.StateReason != "" &&
+		!strings.EqualFold(string(spec.StateReason), issue.StateReason) {
+		drifted = append(drifted, "stateReason")
+	}
+	return drifted
+}
+
+// sameStringSet: check if both slices hold the same strings, ignoring order and case (github treats label
+// names and logins case insensitively)
+func sameStringSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := map[string]int{}
+	for _, item := range a {
+		counts[strings.ToLower(item)]++
+	}
+	for _, item := range b {
+		counts[strings.ToLower(item)]--
+	}
+	for _, count := range counts {
+		if count != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // Helper functions to check and remove string from a slice of strings.
 func containsString(slice []string, s string) bool {
 	for _, item := range slice {
@@ -178,10 +437,42 @@ func containsString(slice []string, s string) bool {
 }
 
 func (r *GitHubIssueReconciler) updateStatus(ghIssue examplev1alpha1.GitHubIssue, realWorldIssue *github.Issue,
-	ctx context.Context) error {
+	comments []examplev1alpha1.CommentStatus, ctx context.Context) error {
 	patch := client.MergeFrom(ghIssue.DeepCopy())
 	ghIssue.Status.State = realWorldIssue.State
 	ghIssue.Status.LastUpdateTimestamp = realWorldIssue.LastUpdateTimestamp
+	if issueNumber, err := realWorldIssue.IssueNumber.Int64(); err == nil {
+		ghIssue.Status.IssueNumber = int(issueNumber)
+	}
+	ghIssue.Status.NodeID = realWorldIssue.NodeID
+	ghIssue.Status.Repo = ghIssue.Spec.Repo
+	ghIssue.Status.HTMLURL = realWorldIssue.HTMLURL
+	ghIssue.Status.Author = realWorldIssue.AuthorLogin()
+	ghIssue.Status.CreatedAt = metaTime(realWorldIssue.CreatedAt)
+	ghIssue.Status.UpdatedAt = parseMetaTime(realWorldIssue.LastUpdateTimestamp)
+	ghIssue.Status.ClosedAt = metaTime(realWorldIssue.ClosedAt)
+	ghIssue.Status.CommentCount = realWorldIssue.Comments
+	ghIssue.Status.Labels = realWorldIssue.LabelNames()
+	ghIssue.Status.Assignees = realWorldIssue.AssigneeLogins()
+	ghIssue.Status.Comments = comments
+	markSynced(&ghIssue)
 	err := r.Client.Status().Patch(ctx, &ghIssue, patch)
 	return err
 }
+
+//metaTime: a github timestamp as set in the status, nil when github didn't send it
+func metaTime(t *time.Time) *metav1.Time {
+	if t == nil {
+		return nil
+	}
+	return &metav1.Time{Time: *t}
+}
+
+//parseMetaTime: an RFC 3339 github timestamp as set in the status, nil when it's missing or malformed
+func parseMetaTime(value string) *metav1.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return metaTime(&t)
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
 	"github.com/ShellyKatz/example-operator/controllers/github"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -9,10 +10,12 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
 	"testing"
 	"time"
 )
@@ -25,6 +28,7 @@ func newGithubIssueRuntimeObject(title, description, state, lastUpdateTimeStamp
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "ghTest",
 			Namespace: "default",
+			UID:       "ghTest-uid",
 			Finalizers: finalizersList,
 		},
 		Spec: examplev1alpha1.GitHubIssueSpec{
@@ -53,6 +57,7 @@ func createReconciler(fakeGithubClient *github.FakeClient, fakeK8sClient client.
 		Log:          ctrl.Log.WithName("controllers").WithName("GitHubIssue"),
 		Scheme:       s,
 		GithubClient: fakeGithubClient,
+		Recorder:     record.NewFakeRecorder(100),
 	}
 }
 
@@ -80,6 +85,11 @@ func createAndAddScheme() *runtime.Scheme{
 	return s
 }
 
+// stampedDescription is the description of the test object's issue on github, ending with its ownership marker
+func stampedDescription(description string) string {
+	return desiredSpec(newGithubIssueRuntimeObject("testIssue", description, "", "", nil, false)).Description
+}
+
 func createFakeGithubIssue()  github.Issue{
 	return github.Issue{
 		Repo:                "testUser/testRepo",
@@ -187,7 +197,7 @@ func TestSuccessfulEdit(t *testing.T) {
 	if len(fakeGithubClient.Issues) != 1 {
 		t.Errorf("Expected repo to stay in len 1 but got len: %d", len(fakeGithubClient.Issues))
 	}
-	if fakeGithubClient.Issues[0].Description != "testing...edit!" {
+	if fakeGithubClient.Issues[0].Description != stampedDescription("testing...edit!") {
 		t.Errorf("Expected description to be: testing...edit! but got: %s",
 			fakeGithubClient.Issues[0].Description)
 	}
@@ -279,4 +289,621 @@ func TestSuccessfulStatusUpdate(t *testing.T) {
 
 func TestUnsuccessfulStatusUpdate(t *testing.T) {
 	t.Skip()
-}
\ No newline at end of file
+}
+func TestCreateRecordsIssueNumber(t *testing.T) {
+	//given an empty repository and a valid ghIssue object
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "",
+		[]string{}, false)
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the number and node id of the created issue are recorded in the status
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	updated := examplev1alpha1.GitHubIssue{}
+	if err = fakeK8sClient.Get(context.Background(), createReq().NamespacedName, &updated); err != nil {
+		t.Fatalf("Expected to get the object but got an error: %v", err)
+	}
+	if updated.Status.IssueNumber != 1 {
+		t.Errorf("Expected issue number 1 but got: %d", updated.Status.IssueNumber)
+	}
+	if updated.Status.NodeID != fakeGithubClient.Issues[0].NodeID {
+		t.Errorf("Expected node id %s but got: %s", fakeGithubClient.Issues[0].NodeID, updated.Status.NodeID)
+	}
+}
+
+func TestStatusRecordsIssueDetails(t *testing.T) {
+	//given an empty repository and an object with a managed comment
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+	ghIssueObj.Spec.Comments = []examplev1alpha1.IssueComment{{Name: "note", Body: "a note"}}
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the address, number, author, timestamps and comment count of the issue are in the status
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	status := getGithubIssueObject(t, fakeK8sClient).Status
+	issue := fakeGithubClient.Issues[0]
+	if status.HTMLURL != issue.HTMLURL || status.IssueNumber != 1 || status.Author != github.FakeLogin {
+		t.Errorf("Expected the url, number and author of the issue but got: %s, %d, %s", status.HTMLURL,
+			status.IssueNumber, status.Author)
+	}
+	if status.CreatedAt == nil || !status.CreatedAt.Time.Equal(*issue.CreatedAt) || status.ClosedAt != nil {
+		t.Errorf("Expected the creation time of an open issue but got: %v, %v", status.CreatedAt, status.ClosedAt)
+	}
+	if status.UpdatedAt == nil || status.UpdatedAt.Format(time.RFC3339) != issue.LastUpdateTimestamp {
+		t.Errorf("Expected the update time %s but got: %v", issue.LastUpdateTimestamp, status.UpdatedAt)
+	}
+	if status.CommentCount != 1 {
+		t.Errorf("Expected one comment but got: %d", status.CommentCount)
+	}
+}
+
+func TestRenamedIssueIsFetchedByNumber(t *testing.T) {
+	//given an issue that was renamed on github and another issue that carries the object's title
+	renamed := createFakeGithubIssue()
+	renamed.Title = "renamed on github"
+	sameTitle := createFakeGithubIssue()
+	sameTitle.IssueNumber = "2"
+	sameTitle.Description = "someone else's issue"
+
+	fakeRepo := []*github.Issue{&renamed, &sameTitle}
+	fakeGithubClient := github.NewFakeClient(fakeRepo, false, "no error")
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "open", "",
+		[]string{FinalizerName}, false)
+	ghIssueObj.Status.IssueNumber = 1
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the tracked issue gets its title back, nothing is created and the other issue is untouched
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	if len(fakeGithubClient.Issues) != 2 {
+		t.Errorf("Expected repo to stay in len 2 but got len: %d", len(fakeGithubClient.Issues))
+	}
+	if renamed.Title != "testIssue" {
+		t.Errorf("Expected title to be restored to testIssue but got: %s", renamed.Title)
+	}
+	if sameTitle.Description != "someone else's issue" {
+		t.Errorf("Expected the other issue to be untouched but got description: %s", sameTitle.Description)
+	}
+}
+
+func TestTrackedIssueMissing(t *testing.T) {
+	//given an object that tracks an issue number which doesn't exist on github
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "open", "",
+		[]string{FinalizerName}, false)
+	ghIssueObj.Status.IssueNumber = 7
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then reconciler returns an error and doesn't open a new issue
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if len(fakeGithubClient.Issues) != 0 {
+		t.Errorf("Expected repo to stay empty but got len: %d", len(fakeGithubClient.Issues))
+	}
+}
+
+func TestRateLimitedRequeuesAfterReset(t *testing.T) {
+	//given a github client that is rate limited for the next 10 minutes
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	fakeGithubClient.RateLimitReset = time.Now().Add(10 * time.Minute)
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "",
+		[]string{FinalizerName}, false)
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	result, err := r.Reconcile(context.Background(), createReq())
+
+	//then reconciler returns no error and requeues once the limit resets
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	if result.RequeueAfter < 9*time.Minute || result.RequeueAfter > 11*time.Minute {
+		t.Errorf("Expected to requeue after about 10 minutes but got: %v", result.RequeueAfter)
+	}
+	if len(fakeGithubClient.Issues) != 0 {
+		t.Errorf("Expected repo to stay empty but got len: %d", len(fakeGithubClient.Issues))
+	}
+}
+
+func TestLabelsAndAssigneesDrift(t *testing.T) {
+	//given an issue whose title and description match but labels and assignees were changed on github
+	issue := createFakeGithubIssue()
+	issue.Labels = []github.Label{{Name: "wontfix"}}
+	issue.Assignees = []github.User{{Login: "someone"}}
+
+	fakeRepo := []*github.Issue{&issue}
+	fakeGithubClient := github.NewFakeClient(fakeRepo, false, "no error")
+
+	milestone := 3
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "open", "",
+		[]string{FinalizerName}, false)
+	ghIssueObj.Spec.Labels = []string{"bug", "triage"}
+	ghIssueObj.Spec.Assignees = []string{"ShellyKatz"}
+	ghIssueObj.Spec.Milestone = &milestone
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the labels, assignees and milestone are set on github and reported in the status
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	if !sameStringSet(issue.LabelNames(), []string{"bug", "triage"}) || issue.MilestoneNumber() != 3 {
+		t.Errorf("Expected labels and milestone to be set but got: %v, %d", issue.LabelNames(), issue.MilestoneNumber())
+	}
+	updated := examplev1alpha1.GitHubIssue{}
+	if err = fakeK8sClient.Get(context.Background(), createReq().NamespacedName, &updated); err != nil {
+		t.Fatalf("Expected to get the object but got an error: %v", err)
+	}
+	if !sameStringSet(updated.Status.Labels, []string{"bug", "triage"}) ||
+		!sameStringSet(updated.Status.Assignees, []string{"ShellyKatz"}) {
+		t.Errorf("Expected status to report labels and assignees but got: %v, %v",
+			updated.Status.Labels, updated.Status.Assignees)
+	}
+}
+
+func TestDriftedFields(t *testing.T) {
+	milestone := 2
+	spec := examplev1alpha1.GitHubIssueSpec{Title: "title", Description: "body",
+		Labels: []string{"Bug", "triage"}, Assignees: []string{"a"}, Milestone: &milestone}
+	issue := &github.Issue{Title: "title", Description: "body", State: "open",
+		Labels:    []github.Label{{Name: "triage"}, {Name: "bug"}},
+		Assignees: []github.User{{Login: "A"}},
+		Milestone: &github.Milestone{Number: 2}}
+
+	if drifted := driftedFields(spec, issue); len(drifted) != 0 {
+		t.Errorf("Expected no drift but got: %v", drifted)
+	}
+	issue.Description = "edited on github"
+	issue.Milestone = nil
+	if drifted := driftedFields(spec, issue); len(drifted) != 2 || drifted[0] != "description" || drifted[1] != "milestone" {
+		t.Errorf("Expected description and milestone to drift but got: %v", drifted)
+	}
+	//labels and assignees that aren't in the spec aren't managed
+	spec.Labels, spec.Assignees, spec.Milestone = nil, nil, nil
+	issue.Description = "body"
+	if drifted := driftedFields(spec, issue); len(drifted) != 0 {
+		t.Errorf("Expected unmanaged fields not to drift but got: %v", drifted)
+	}
+	//the reason of a closed issue is managed when the spec sets one
+	spec.State, spec.StateReason = examplev1alpha1.IssueStateClosed, examplev1alpha1.StateReasonNotPlanned
+	issue.State, issue.StateReason = "closed", "completed"
+	if drifted := driftedFields(spec, issue); len(drifted) != 1 || drifted[0] != "stateReason" {
+		t.Errorf("Expected the state reason to drift but got: %v", drifted)
+	}
+	spec.StateReason = ""
+	if drifted := driftedFields(spec, issue); len(drifted) != 0 {
+		t.Errorf("Expected an unset state reason not to drift but got: %v", drifted)
+	}
+}
+
+func TestStateReasonChangedOnGithubIsReverted(t *testing.T) {
+	//given a tracked issue closed as not planned by the spec, and reopened and closed as completed on github
+	issue := createFakeGithubIssue()
+	issue.Description = stampedDescription("testing...")
+	issue.State, issue.StateReason = "closed", "completed"
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "closed", "", []string{FinalizerName}, false)
+	ghIssueObj.Spec.State = examplev1alpha1.IssueStateClosed
+	ghIssueObj.Spec.StateReason = examplev1alpha1.StateReasonNotPlanned
+	ghIssueObj.Status.IssueNumber = 1
+	r := createReconciler(fakeGithubClient, newFakeK8sClient(ghIssueObj), s)
+
+	//when reconciling
+	if _, err := r.Reconcile(context.Background(), createReq()); err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+
+	//then the reason of the spec is restored
+	if issue.State != "closed" || issue.StateReason != "not_planned" {
+		t.Errorf("Expected the issue closed as not_planned but got: %s, %s", issue.State, issue.StateReason)
+	}
+}
+
+func TestDeleteWithOrphanPolicy(t *testing.T) {
+	//given a ghIssue being deleted with the Orphan deletion policy
+	issue := createFakeGithubIssue()
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "",
+		[]string{FinalizerName}, true)
+	ghIssueObj.Spec.DeletionPolicy = examplev1alpha1.DeletionPolicyOrphan
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the issue stays open
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	if issue.State != "open" {
+		t.Errorf("Expected issue's state to stay \"open\" but got: %s", issue.State)
+	}
+}
+
+func TestDeleteWithCommentPolicy(t *testing.T) {
+	//given a ghIssue being deleted with the CloseWithComment deletion policy and a state reason
+	issue := createFakeGithubIssue()
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "",
+		[]string{FinalizerName}, true)
+	ghIssueObj.Spec.DeletionPolicy = examplev1alpha1.DeletionPolicyCloseWithComment
+	ghIssueObj.Spec.DeletionComment = "moved to another tracker"
+	ghIssueObj.Spec.StateReason = examplev1alpha1.StateReasonNotPlanned
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the comment is posted and the issue is closed as not planned
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	comments := fakeGithubClient.Comments["1"]
+	if len(comments) != 1 || comments[0].Body != "moved to another tracker\n\n"+deletionCommentMarker {
+		t.Errorf("Expected the deletion comment to be posted but got: %v", comments)
+	}
+	if issue.State != "closed" || issue.StateReason != "not_planned" {
+		t.Errorf("Expected issue to be closed as not_planned but got: %s %s", issue.State, issue.StateReason)
+	}
+}
+
+func TestDeleteWithOrphanPolicyDoesntCallGithub(t *testing.T) {
+	//given an orphaned ghIssue being deleted whose credentials secret is gone, and github unreachable
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	fakeGithubClient.RateLimitReset = time.Now().Add(time.Hour)
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, true)
+	ghIssueObj.Spec.DeletionPolicy = examplev1alpha1.DeletionPolicyOrphan
+	ghIssueObj.Spec.TokenSecretRef = &examplev1alpha1.SecretKeyReference{Name: "deleted-secret"}
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the finalizer is removed all the same
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if updated := getGithubIssueObject(t, fakeK8sClient); containsString(updated.GetFinalizers(), FinalizerName) {
+		t.Errorf("Expected the finalizer to be removed but got: %v", updated.GetFinalizers())
+	}
+}
+
+func TestDeletionCommentNotPostedTwice(t *testing.T) {
+	//given a ghIssue deleted with the CloseWithComment policy, and github failing to close the issue once
+	issue := createFakeGithubIssue()
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, true, github.DeleteError)
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, true)
+	ghIssueObj.Spec.DeletionPolicy = examplev1alpha1.DeletionPolicyCloseWithComment
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when the deletion fails after commenting, and is retried
+	if _, err := r.Reconcile(context.Background(), createReq()); err == nil {
+		t.Fatal("Expected the failed close to be returned")
+	}
+	fakeGithubClient.Err = nil
+	if _, err := r.Reconcile(context.Background(), createReq()); err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+
+	//then the issue is closed with a single deletion comment
+	if comments := fakeGithubClient.Comments["1"]; len(comments) != 1 {
+		t.Errorf("Expected a single deletion comment but got: %d", len(comments))
+	}
+	if issue.State != "closed" {
+		t.Errorf("Expected the issue to be closed but got: %s", issue.State)
+	}
+}
+
+func TestReopenIssueClosedByHand(t *testing.T) {
+	//given an issue that was closed on github while the spec wants it open
+	issue := createFakeGithubIssue()
+	issue.State = "closed"
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "closed", "",
+		[]string{FinalizerName}, false)
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the issue is reopened
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	if issue.State != "open" {
+		t.Errorf("Expected issue's state to be \"open\" but got: %s", issue.State)
+	}
+}
+
+func TestCloseIssueFromSpec(t *testing.T) {
+	//given an open issue and a spec that wants it closed as completed
+	issue := createFakeGithubIssue()
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "open", "",
+		[]string{FinalizerName}, false)
+	ghIssueObj.Spec.State = examplev1alpha1.IssueStateClosed
+	ghIssueObj.Spec.StateReason = examplev1alpha1.StateReasonCompleted
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the issue is closed with the reason and the status reflects it
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	if issue.State != "closed" || issue.StateReason != "completed" {
+		t.Errorf("Expected issue to be closed as completed but got: %s %s", issue.State, issue.StateReason)
+	}
+	updated := examplev1alpha1.GitHubIssue{}
+	if err = fakeK8sClient.Get(context.Background(), createReq().NamespacedName, &updated); err != nil {
+		t.Fatalf("Expected to get the object but got an error: %v", err)
+	}
+	if updated.Status.State != "closed" {
+		t.Errorf("Expected status state \"closed\" but got: %s", updated.Status.State)
+	}
+}
+
+func TestResyncIntervalRequeues(t *testing.T) {
+	//given a reconciler resyncing every 10 minutes and an object resyncing every minute
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+	otherObj := ghIssueObj.DeepCopy()
+	otherObj.Name = "ghOther"
+	otherObj.Spec.Title = "otherIssue"
+	otherObj.Spec.ResyncInterval = &metav1.Duration{Duration: time.Minute}
+	fakeK8sClient := newFakeK8sClient(ghIssueObj, *otherObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+	r.ResyncInterval = 10 * time.Minute
+
+	//when reconciling both objects
+	result, err := r.Reconcile(context.Background(), createReq())
+	otherResult, otherErr := r.Reconcile(context.Background(), reconcile.Request{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: "ghOther"},
+	})
+
+	//then each is requeued after its own interval
+	if err != nil || otherErr != nil {
+		t.Fatalf("Expected no errors but got: %v, %v", err, otherErr)
+	}
+	if result.RequeueAfter != 10*time.Minute {
+		t.Errorf("Expected to requeue after the default interval but got: %v", result.RequeueAfter)
+	}
+	if otherResult.RequeueAfter != time.Minute {
+		t.Errorf("Expected to requeue after the object's interval but got: %v", otherResult.RequeueAfter)
+	}
+}
+
+func TestDriftOnGithubIsRevertedAndRecorded(t *testing.T) {
+	//given a synced object whose issue's description was edited on github
+	issue := createFakeGithubIssue()
+	issue.Description = "edited on github"
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "open", "", []string{FinalizerName}, false)
+	ghIssueObj.Generation = 2
+	ghIssueObj.Status.ObservedGeneration = 2
+	ghIssueObj.Status.SyncedGeneration = 2
+	ghIssueObj.Status.IssueNumber = 1
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the description is reverted and the drift is recorded as an event
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	if issue.Description != stampedDescription("testing...") {
+		t.Errorf("Expected the description to be reverted but got: %q", issue.Description)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "DriftDetected") || !strings.Contains(event, "description") {
+			t.Errorf("Expected a drift event for the description but got: %q", event)
+		}
+	default:
+		t.Errorf("Expected a drift event but none was recorded")
+	}
+}
+
+func TestSpecChangeIsNotRecordedAsDrift(t *testing.T) {
+	//given an object whose spec changed since the last sync
+	issue := createFakeGithubIssue()
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "new description", "open", "", []string{FinalizerName}, false)
+	ghIssueObj.Generation = 3
+	ghIssueObj.Status.ObservedGeneration = 2
+	ghIssueObj.Status.SyncedGeneration = 2
+	ghIssueObj.Status.IssueNumber = 1
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	//when reconciling
+	_, _ = r.Reconcile(context.Background(), createReq())
+
+	//then the issue is edited without a drift event
+	if issue.Description != stampedDescription("new description") {
+		t.Errorf("Expected the description to be edited but got: %q", issue.Description)
+	}
+	select {
+	case event := <-recorder.Events:
+		if strings.Contains(event, "DriftDetected") {
+			t.Errorf("Expected no drift event but got: %q", event)
+		}
+	default:
+	}
+}
+
+func TestSpecChangeRetriedAfterFailedEditIsNotRecordedAsDrift(t *testing.T) {
+	//given an object whose spec changed since the last sync, and github failing the edit once
+	issue := createFakeGithubIssue()
+	issue.Description = stampedDescription("testing...")
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, true, github.EditError)
+
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "new description", "open", "", []string{FinalizerName}, false)
+	ghIssueObj.Generation = 3
+	ghIssueObj.Status.ObservedGeneration = 2
+	ghIssueObj.Status.SyncedGeneration = 2
+	ghIssueObj.Status.IssueNumber = 1
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	//when the edit fails, and is retried
+	if _, err := r.Reconcile(context.Background(), createReq()); err == nil {
+		t.Fatal("Expected the failed edit to be returned")
+	}
+	fakeGithubClient.Err = nil
+	if _, err := r.Reconcile(context.Background(), createReq()); err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+
+	//then the issue is edited without a drift event, and the generation is synced
+	if issue.Description != stampedDescription("new description") {
+		t.Errorf("Expected the description to be edited but got: %q", issue.Description)
+	}
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.Contains(event, "DriftDetected") {
+			t.Errorf("Expected no drift event but got: %q", event)
+		}
+	}
+	if updated := getGithubIssueObject(t, fakeK8sClient); updated.Status.SyncedGeneration != 3 {
+		t.Errorf("Expected synced generation 3 but got: %d", updated.Status.SyncedGeneration)
+	}
+}
+
+// failingStatusClient is a client whose status writes fail once the first allowed ones went through
+type failingStatusClient struct {
+	client.Client
+	allowed int
+}
+
+func (c *failingStatusClient) Status() client.StatusWriter {
+	return &failingStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+type failingStatusWriter struct {
+	client.StatusWriter
+	client *failingStatusClient
+}
+
+func (w *failingStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch,
+	opts ...client.PatchOption) error {
+	if w.client.allowed == 0 {
+		return errors.New("status patch failed")
+	}
+	w.client.allowed--
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
+
+func TestCreatedIssueNumberSurvivesFailedStatusUpdate(t *testing.T) {
+	//given an empty repository and a status update that fails after the issue number was recorded
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+	r := createReconciler(fakeGithubClient, &failingStatusClient{Client: fakeK8sClient, allowed: 1}, s)
+
+	//when reconciling fails, and is retried
+	if _, err := r.Reconcile(context.Background(), createReq()); err == nil {
+		t.Fatal("Expected the failed status update to be returned")
+	}
+	r = createReconciler(fakeGithubClient, fakeK8sClient, s)
+	if _, err := r.Reconcile(context.Background(), createReq()); err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+
+	//then the issue number was persisted by the first reconcile and no second issue is opened
+	if len(fakeGithubClient.Issues) != 1 {
+		t.Errorf("Expected a single issue but got: %d", len(fakeGithubClient.Issues))
+	}
+	if updated := getGithubIssueObject(t, fakeK8sClient); updated.Status.IssueNumber != 1 {
+		t.Errorf("Expected issue number 1 but got: %d", updated.Status.IssueNumber)
+	}
+}
+
+func TestCreateFindsIssueOfEarlierReconcileByMarker(t *testing.T) {
+	//given an issue the object created before it could record it, renamed on github since
+	issue := createFakeGithubIssue()
+	issue.Title = "renamed on github"
+	issue.Description = stampedDescription("testing...")
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the issue is found by the object's marker, tracked and brought back to the spec instead of duplicated
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if len(fakeGithubClient.Issues) != 1 || fakeGithubClient.Issues[0].Title != "testIssue" {
+		t.Errorf("Expected the single issue to be renamed back but got: %d issues", len(fakeGithubClient.Issues))
+	}
+	if updated := getGithubIssueObject(t, fakeK8sClient); updated.Status.IssueNumber != 1 {
+		t.Errorf("Expected issue number 1 but got: %d", updated.Status.IssueNumber)
+	}
+}
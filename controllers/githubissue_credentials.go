@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+// TokenEnvVar is the environment variable holding the token used when the object has no credentials reference
+const TokenEnvVar = "GITHUB_TOKEN"
+
+// DefaultTokenSecretKey is the key read from the secret when tokenSecretRef has no key
+const DefaultTokenSecretKey = "token"
+
+// DefaultPrivateKeySecretKey is the key read from the secret when githubAppRef.privateKeySecretRef has no key
+const DefaultPrivateKeySecretKey = "private-key.pem"
+
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+//resolveTokenSource: the credentials of the object - a github app when it references one, otherwise the token
+//from the secret it references, falling back to the operator's environment for the operator's github only
+func (r *GitHubIssueReconciler) resolveTokenSource(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue) (github.TokenSource, error) {
+	if ghIssue.Spec.TokenSecretRef != nil && ghIssue.Spec.GithubAppRef != nil {
+		return nil, fmt.Errorf("tokenSecretRef and githubAppRef can't be set together")
+	}
+	if app := ghIssue.Spec.GithubAppRef; app != nil {
+		if r.AppTokens == nil {
+			return nil, fmt.Errorf("github app authentication is not configured in the operator")
+		}
+		privateKey, err := r.readSecretKey(ctx, ghIssue.Namespace, app.PrivateKeySecretRef, DefaultPrivateKeySecretKey)
+		if err != nil {
+			return nil, err
+		}
+		return r.AppTokens.Get(ghIssue.Spec.BaseURL, app.AppID, app.InstallationID, privateKey)
+	}
+	if ref := ghIssue.Spec.TokenSecretRef; ref != nil {
+		token, err := r.readSecretKey(ctx, ghIssue.Namespace, *ref, DefaultTokenSecretKey)
+		if err != nil {
+			return nil, err
+		}
+		return github.StaticToken(token), nil
+	}
+	// the operator's token is meant for the operator's github, a host set in the object could be anyone's
+	if ghIssue.Spec.BaseURL != "" {
+		return nil, fmt.Errorf("spec.baseURL requires tokenSecretRef or githubAppRef, the operator's %s isn't sent to another github",
+			TokenEnvVar)
+	}
+	return github.StaticToken(os.Getenv(TokenEnvVar)), nil
+}
+
+//readSecretKey: read a key of a secret in the namespace
+func (r *GitHubIssueReconciler) readSecretKey(ctx context.Context, namespace string,
+	ref examplev1alpha1.SecretKeyReference, defaultKey string) ([]byte, error) {
+	key := ref.Key
+	if key == "" {
+		key = defaultKey
+	}
+
+	secret := corev1.Secret{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, &secret); err != nil {
+		return nil, fmt.Errorf("getting secret %s: %w", ref.Name, err)
+	}
+	value, ok := secret.Data[key]
+	if !ok || len(value) == 0 {
+		return nil, fmt.Errorf("secret %s has no key %s", ref.Name, key)
+	}
+	return value, nil
+}
+
+//referencedSecrets: names of the secrets holding the credentials of the object
+func referencedSecrets(ghIssue examplev1alpha1.GitHubIssue) []string {
+	var names []string
+	if ghIssue.Spec.TokenSecretRef != nil {
+		names = append(names, ghIssue.Spec.TokenSecretRef.Name)
+	}
+	if ghIssue.Spec.GithubAppRef != nil {
+		names = append(names, ghIssue.Spec.GithubAppRef.PrivateKeySecretRef.Name)
+	}
+	return names
+}
+
+//findObjectsForSecret: map a secret to the GitHubIssue objects in its namespace that reference it, so that
+//rotating credentials reconciles them
+func (r *GitHubIssueReconciler) findObjectsForSecret(secret client.Object) []reconcile.Request {
+	ghIssues := examplev1alpha1.GitHubIssueList{}
+	if err := r.Client.List(context.Background(), &ghIssues, client.InNamespace(secret.GetNamespace())); err != nil {
+		r.Log.Error(err, "unable to list githubissues for secret", "secret", secret.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, ghIssue := range ghIssues.Items {
+		if containsString(referencedSecrets(ghIssue), secret.GetName()) {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: ghIssue.Namespace, Name: ghIssue.Name},
+			})
+		}
+	}
+	return requests
+}
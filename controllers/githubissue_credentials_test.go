@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+func newTokenSecret(name string, data map[string]string) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       map[string][]byte{},
+	}
+	for key, value := range data {
+		secret.Data[key] = []byte(value)
+	}
+	return secret
+}
+
+func TestTokenFromSecretRef(t *testing.T) {
+	//given an object referencing a secret with a custom key
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "",
+		[]string{FinalizerName}, false)
+	ghIssueObj.Spec.TokenSecretRef = &examplev1alpha1.SecretKeyReference{Name: "team-token", Key: "pat"}
+	fakeK8sClient := fake.NewClientBuilder().WithRuntimeObjects(ghIssueObj.DeepCopy(),
+		newTokenSecret("team-token", map[string]string{"pat": "secret-token"})).Build()
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then github is called with the token from the secret
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	if fakeGithubClient.LastToken != "secret-token" {
+		t.Errorf("Expected token from the secret but got: %q", fakeGithubClient.LastToken)
+	}
+}
+
+func TestTokenFromEnvWithoutSecretRef(t *testing.T) {
+	//given an object without a secret reference and a token in the environment
+	previous := os.Getenv(TokenEnvVar)
+	os.Setenv(TokenEnvVar, "env-token")
+	defer os.Setenv(TokenEnvVar, previous)
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "",
+		[]string{FinalizerName}, false)
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then github is called with the token from the environment
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	if fakeGithubClient.LastToken != "env-token" {
+		t.Errorf("Expected token from the environment but got: %q", fakeGithubClient.LastToken)
+	}
+}
+
+func TestEnvTokenNotSentToObjectBaseURL(t *testing.T) {
+	//given an object pointing at its own github host without credentials of its own
+	previous := os.Getenv(TokenEnvVar)
+	os.Setenv(TokenEnvVar, "env-token")
+	defer os.Setenv(TokenEnvVar, previous)
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+	ghIssueObj.Spec.BaseURL = "https://attacker.example.com/"
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then github isn't called and the credentials are reported invalid
+	if err == nil {
+		t.Error("Expected an error but got none")
+	}
+	if fakeGithubClient.LastToken != "" {
+		t.Errorf("Expected the operator's token not to be used but got: %q", fakeGithubClient.LastToken)
+	}
+	expectCondition(t, getGithubIssueObject(t, fakeK8sClient), examplev1alpha1.ConditionCredentialsValid,
+		metav1.ConditionFalse, ReasonInvalidCredentials)
+}
+
+func TestMissingTokenSecret(t *testing.T) {
+	//given an object referencing a secret that doesn't exist
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "",
+		[]string{FinalizerName}, false)
+	ghIssueObj.Spec.TokenSecretRef = &examplev1alpha1.SecretKeyReference{Name: "missing"}
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then reconciler returns an error without calling github
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if len(fakeGithubClient.Issues) != 0 {
+		t.Errorf("Expected repo to stay empty but got len: %d", len(fakeGithubClient.Issues))
+	}
+}
+
+func TestMissingTokenSecretOnDeletion(t *testing.T) {
+	//given an object being deleted whose token secret was deleted first, as when its namespace is torn down
+	issue := createFakeGithubIssue()
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, true)
+	ghIssueObj.Spec.TokenSecretRef = &examplev1alpha1.SecretKeyReference{Name: "deleted-secret"}
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	result, err := r.Reconcile(context.Background(), createReq())
+
+	//then it isn't retried, the condition tells the user how to let the object go
+	if err != nil || result.Requeue || result.RequeueAfter != 0 {
+		t.Fatalf("Expected no retry but got: %v, %v", result, err)
+	}
+	updated := getGithubIssueObject(t, fakeK8sClient)
+	expectCondition(t, updated, examplev1alpha1.ConditionCredentialsValid, metav1.ConditionFalse, ReasonInvalidCredentials)
+	if condition := meta.FindStatusCondition(updated.Status.Conditions, examplev1alpha1.ConditionCredentialsValid); !strings.Contains(condition.Message, "Orphan") {
+		t.Errorf("Expected the condition to mention the Orphan policy but got: %s", condition.Message)
+	}
+
+	//and once the user orphans the issue, the object goes
+	updated.Spec.DeletionPolicy = examplev1alpha1.DeletionPolicyOrphan
+	if err = fakeK8sClient.Update(context.Background(), &updated); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.Reconcile(context.Background(), createReq()); err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if updated = getGithubIssueObject(t, fakeK8sClient); containsString(updated.GetFinalizers(), FinalizerName) {
+		t.Errorf("Expected the finalizer to be removed but got: %v", updated.GetFinalizers())
+	}
+	if issue.State != "open" {
+		t.Errorf("Expected the orphaned issue to stay open but got: %s", issue.State)
+	}
+}
+
+func TestSecretMapsToReferencingObjects(t *testing.T) {
+	//given two objects, only one of them referencing the secret
+	referencing := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{}, false)
+	referencing.Spec.TokenSecretRef = &examplev1alpha1.SecretKeyReference{Name: "team-token"}
+	other := newGithubIssueRuntimeObject("otherIssue", "testing...", "", "", []string{}, false)
+	other.Name = "other"
+	fakeK8sClient := fake.NewClientBuilder().WithRuntimeObjects([]runtime.Object{
+		referencing.DeepCopy(), other.DeepCopy()}...).Build()
+
+	r := createReconciler(github.NewFakeClient([]*github.Issue{}, false, "no error"), fakeK8sClient, s)
+
+	//when the secret changes
+	requests := r.findObjectsForSecret(newTokenSecret("team-token", nil))
+
+	//then only the referencing object is reconciled
+	if len(requests) != 1 || requests[0] != createReq() {
+		t.Errorf("Expected a request for the referencing object only but got: %v", requests)
+	}
+}
+
+func TestTokenFromGithubApp(t *testing.T) {
+	//given an object referencing a github app whose installation token is served by a fake github
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/app/installations/42/access_tokens" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"token":"installation-token","expires_at":"` +
+			time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+	}))
+	defer server.Close()
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "",
+		[]string{FinalizerName}, false)
+	ghIssueObj.Spec.GithubAppRef = &examplev1alpha1.GithubAppReference{
+		AppID:               7,
+		InstallationID:      42,
+		PrivateKeySecretRef: examplev1alpha1.SecretKeyReference{Name: "app-key"},
+	}
+	fakeK8sClient := fake.NewClientBuilder().WithRuntimeObjects(ghIssueObj.DeepCopy(),
+		newTokenSecret("app-key", map[string]string{DefaultPrivateKeySecretKey: string(pemKey)})).Build()
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+	r.AppTokens = &github.AppTokenSources{BaseURL: server.URL + "/"}
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then github is called with the app's installation token
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	if fakeGithubClient.LastToken != "installation-token" {
+		t.Errorf("Expected the installation token but got: %q", fakeGithubClient.LastToken)
+	}
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+// reasons of the events recorded on GitHubIssue objects for their github side effects. failures are recorded
+// with the reason of the Ready condition
+const (
+	EventReasonCreated        = "Created"
+	EventReasonEdited         = "Edited"
+	EventReasonClosed         = "Closed"
+	EventReasonReopened       = "Reopened"
+	EventReasonAdopted        = "Adopted"
+	EventReasonDriftDetected  = "DriftDetected"
+	EventReasonCommentCreated = "CommentCreated"
+	EventReasonCommentEdited  = "CommentEdited"
+	EventReasonCommentDeleted = "CommentDeleted"
+	EventReasonTransferred    = "Transferred"
+	EventReasonRecreated      = "Recreated"
+)
+
+//issueURL: the address of the issue on github, the api address when github didn't send it
+func issueURL(issue *github.Issue) string {
+	if issue.HTMLURL != "" {
+		return issue.HTMLURL
+	}
+	return issue.Repo
+}
+
+//recordIssueEvent: record a normal event about the github issue of the object
+func (r *GitHubIssueReconciler) recordIssueEvent(ghIssue examplev1alpha1.GitHubIssue, reason string,
+	issue *github.Issue, message string) {
+	r.Recorder.Eventf(&ghIssue, corev1.EventTypeNormal, reason, "%s #%s %s", message, issue.IssueNumber, issueURL(issue))
+}
+
+//recordEdit: record the edit of the drifted fields, a change of state as closing or reopening the issue
+func (r *GitHubIssueReconciler) recordEdit(ghIssue examplev1alpha1.GitHubIssue, issue *github.Issue,
+	previousState string, drifted []string) {
+	var edited []string
+	for _, field := range drifted {
+		if field != "state" {
+			edited = append(edited, field)
+		}
+	}
+	if len(edited) > 0 {
+		r.recordIssueEvent(ghIssue, EventReasonEdited, issue, fmt.Sprintf("edited %s of issue", strings.Join(edited, ", ")))
+	}
+	if issue.State != previousState {
+		if issue.State == "closed" {
+			r.recordIssueEvent(ghIssue, EventReasonClosed, issue, "closed issue")
+		} else {
+			r.recordIssueEvent(ghIssue, EventReasonReopened, issue, "reopened issue")
+		}
+	}
+}
+
+//recordDrift: record an event for every managed field that was changed on github
+func (r *GitHubIssueReconciler) recordDrift(ghIssue examplev1alpha1.GitHubIssue, issue *github.Issue, drifted []string) {
+	for _, field := range drifted {
+		r.Recorder.Eventf(&ghIssue, corev1.EventTypeWarning, EventReasonDriftDetected,
+			"%s of issue #%s %s was changed on github, reverting it to the spec", field, issue.IssueNumber, issueURL(issue))
+	}
+}
+
+//recordWarning: record a warning event for a failed reconcile, naming the issue when the object tracks one
+func (r *GitHubIssueReconciler) recordWarning(ghIssue examplev1alpha1.GitHubIssue, reason string, err error) {
+	if ghIssue.Status.IssueNumber == 0 {
+		r.Recorder.Event(&ghIssue, corev1.EventTypeWarning, reason, err.Error())
+		return
+	}
+	r.Recorder.Eventf(&ghIssue, corev1.EventTypeWarning, reason, "issue #%d of %s: %s",
+		ghIssue.Status.IssueNumber, ghIssue.Spec.Repo, err.Error())
+}
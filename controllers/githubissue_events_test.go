@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/tools/record"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+func reconcileAndGetEvents(fakeGithubClient *github.FakeClient, ghIssueObj examplev1alpha1.GitHubIssue) []string {
+	r := createReconciler(fakeGithubClient, newFakeK8sClient(ghIssueObj), s)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	_, _ = r.Reconcile(context.Background(), createReq())
+
+	var events []string
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+	return events
+}
+
+func expectEvent(t *testing.T, events []string, expected ...string) {
+	for _, event := range events {
+		matches := true
+		for _, part := range expected {
+			matches = matches && strings.Contains(event, part)
+		}
+		if matches {
+			return
+		}
+	}
+	t.Errorf("Expected an event with %v but got: %v", expected, events)
+}
+
+func TestCreateRecordsEvent(t *testing.T) {
+	//given an empty repository
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+
+	//when reconciling
+	events := reconcileAndGetEvents(fakeGithubClient, ghIssueObj)
+
+	//then the creation is recorded with the issue's number and url
+	expectEvent(t, events, "Normal", EventReasonCreated, "#1", "https://github.com/testUser/testRepo/issues/1")
+}
+
+func TestAdoptRecordsEvent(t *testing.T) {
+	//given an issue opened before the object
+	issue := createFakeGithubIssue()
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+
+	//when reconciling
+	events := reconcileAndGetEvents(fakeGithubClient, ghIssueObj)
+
+	//then the adoption is recorded
+	expectEvent(t, events, "Normal", EventReasonAdopted, "#1")
+}
+
+func TestOwnIssueFoundByTitleIsNotAdopted(t *testing.T) {
+	//given the issue the object created, found by title because its number wasn't recorded
+	issue := createFakeGithubIssue()
+	issue.Description = stampedDescription("testing...")
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+
+	//when reconciling
+	events := reconcileAndGetEvents(fakeGithubClient, ghIssueObj)
+
+	//then it isn't reported as an adoption
+	for _, event := range events {
+		if strings.Contains(event, EventReasonAdopted) {
+			t.Errorf("Expected no adoption event but got: %s", event)
+		}
+	}
+}
+
+func TestEditAndReopenRecordEvents(t *testing.T) {
+	//given a tracked issue that was renamed and closed
+	issue := createFakeGithubIssue()
+	issue.Title = "renamed"
+	issue.State = "closed"
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+	ghIssueObj.Status.IssueNumber = 1
+
+	//when reconciling
+	events := reconcileAndGetEvents(fakeGithubClient, ghIssueObj)
+
+	//then the edit and the reopening are recorded separately
+	expectEvent(t, events, "Normal", EventReasonEdited, "title", "#1")
+	expectEvent(t, events, "Normal", EventReasonReopened, "#1")
+}
+
+func TestFailuresRecordWarnings(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(*github.FakeClient, *examplev1alpha1.GitHubIssue)
+		reason string
+	}{
+		{"api error", func(fake *github.FakeClient, _ *examplev1alpha1.GitHubIssue) {
+			fake.Err = errors.New(github.CreatError)
+		}, ReasonGithubError},
+		{"rate limited", func(fake *github.FakeClient, _ *examplev1alpha1.GitHubIssue) {
+			fake.RateLimitReset = time.Now().Add(time.Minute)
+		}, ReasonRateLimited},
+		{"missing credentials", func(_ *github.FakeClient, ghIssueObj *examplev1alpha1.GitHubIssue) {
+			ghIssueObj.Spec.TokenSecretRef = &examplev1alpha1.SecretKeyReference{Name: "missing"}
+		}, ReasonInvalidCredentials},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			//given a reconcile that fails
+			fakeGithubClient := github.NewFakeClient([]*github.Issue{}, false, "no error")
+			ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+			test.setup(fakeGithubClient, &ghIssueObj)
+
+			//when reconciling
+			events := reconcileAndGetEvents(fakeGithubClient, ghIssueObj)
+
+			//then the failure is recorded as a warning
+			expectEvent(t, events, "Warning", test.reason)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+// ownerMarkerFormat is the hidden marker ending the body of the issues the operator manages, it holds the
+// namespace, name and uid of the GitHubIssue owning the issue
+const ownerMarkerFormat = "<!-- githubissue-operator owner: %s/%s uid: %s -->"
+
+var ownerMarker = regexp.MustCompile(`<!-- githubissue-operator owner: ([^/\s]+)/(\S+) uid: (\S+) -->`)
+
+// issueOwner is the GitHubIssue named in the ownership marker of an issue
+type issueOwner struct {
+	Namespace string
+	Name      string
+	UID       string
+}
+
+// ownershipError is returned for an issue another GitHubIssue owns, the adoption policy doesn't allow taking it
+type ownershipError struct {
+	issueNumber string
+	owner       issueOwner
+}
+
+func (e *ownershipError) Error() string {
+	return fmt.Sprintf("issue #%s is owned by the GitHubIssue %s/%s (uid %s), set adoptionPolicy to Always to take it over",
+		e.issueNumber, e.owner.Namespace, e.owner.Name, e.owner.UID)
+}
+
+//ownerOf: the owner in the marker of the issue, false when the issue has no marker
+func ownerOf(issue *github.Issue) (issueOwner, bool) {
+	match := ownerMarker.FindStringSubmatch(issue.Description)
+	if match == nil {
+		return issueOwner{}, false
+	}
+	return issueOwner{Namespace: match[1], Name: match[2], UID: match[3]}, true
+}
+
+//ownerMarkerOf: the ownership marker of the object. it doesn't change for the life of the object (the uid is in
+//it), which makes it the idempotency key of the issue the object creates
+func ownerMarkerOf(ghIssue examplev1alpha1.GitHubIssue) string {
+	return fmt.Sprintf(ownerMarkerFormat, ghIssue.Namespace, ghIssue.Name, ghIssue.UID)
+}
+
+//desiredSpec: the spec as sent to github, with the ownership marker of the object ending the description
+func desiredSpec(ghIssue examplev1alpha1.GitHubIssue) examplev1alpha1.GitHubIssueSpec {
+	spec := *ghIssue.Spec.DeepCopy()
+	marker := ownerMarkerOf(ghIssue)
+	if spec.Description == "" {
+		spec.Description = marker
+	} else {
+		spec.Description += "\n\n" + marker
+	}
+	return spec
+}
+
+//checkOwnership: whether the object manages the issue it fetched. an issue marked as the object's own is always
+//managed, an issue tracked in the status is managed unless another object owns it, and an issue found by title
+//is adopted according to the adoption policy. an issue the object may not take is returned as an
+//*ownershipError, except for the Never policy which opens a new issue instead
+func (r *GitHubIssueReconciler) checkOwnership(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue,
+	issue *github.Issue) (bool, error) {
+	owner, marked := ownerOf(issue)
+	if marked && owner.UID == string(ghIssue.UID) {
+		return true, nil
+	}
+	policy := ghIssue.Spec.AdoptionPolicy
+	if ghIssue.Status.IssueNumber == 0 && policy == examplev1alpha1.AdoptionPolicyNever {
+		return false, nil
+	}
+	if marked && policy != examplev1alpha1.AdoptionPolicyAlways {
+		exists, err := r.ownerExists(ctx, owner)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			return false, &ownershipError{issueNumber: string(issue.IssueNumber), owner: owner}
+		}
+	}
+	return true, nil
+}
+
+//ownerExists: whether the GitHubIssue in the marker of an issue still exists. an orphaned issue keeps the marker
+//of its deleted object, which no longer owns it, nor does an object of the same name created since (its uid differs)
+func (r *GitHubIssueReconciler) ownerExists(ctx context.Context, owner issueOwner) (bool, error) {
+	current := examplev1alpha1.GitHubIssue{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: owner.Namespace, Name: owner.Name}, &current); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("getting the owner of the issue %s/%s: %w", owner.Namespace, owner.Name, err)
+	}
+	return string(current.UID) == owner.UID, nil
+}
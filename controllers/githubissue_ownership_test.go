@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+// ownedByOtherObject is the description of an issue another GitHubIssue manages
+const ownedByOtherObject = "testing...\n\n<!-- githubissue-operator owner: other/ghOther uid: other-uid -->"
+
+// otherObject is the GitHubIssue named in ownedByOtherObject
+func otherObject() examplev1alpha1.GitHubIssue {
+	return examplev1alpha1.GitHubIssue{ObjectMeta: metav1.ObjectMeta{Name: "ghOther", Namespace: "other", UID: "other-uid"}}
+}
+
+func TestOwnerOf(t *testing.T) {
+	issue := &github.Issue{Description: stampedDescription("testing...")}
+	owner, marked := ownerOf(issue)
+	if !marked || owner != (issueOwner{Namespace: "default", Name: "ghTest", UID: "ghTest-uid"}) {
+		t.Errorf("Expected the test object as owner but got: %v, %v", owner, marked)
+	}
+	if _, marked = ownerOf(&github.Issue{Description: "written by a person"}); marked {
+		t.Errorf("Expected an issue without a marker to have no owner")
+	}
+}
+
+func TestAdoptionPolicy(t *testing.T) {
+	tests := []struct {
+		name            string
+		policy          examplev1alpha1.AdoptionPolicy
+		description     string
+		expectErr       bool
+		expectIssues    int
+		expectStampedBy string
+	}{
+		{"never adopts a person's issue", examplev1alpha1.AdoptionPolicyNever, "testing...", false, 2, ""},
+		{"adopts a person's issue by default", "", "testing...", false, 1, "ghTest-uid"},
+		{"never takes another object's issue by default", "", ownedByOtherObject, true, 1, "other-uid"},
+		{"never takes another object's issue if unowned", examplev1alpha1.AdoptionPolicyIfUnowned,
+			ownedByOtherObject, true, 1, "other-uid"},
+		{"always takes another object's issue", examplev1alpha1.AdoptionPolicyAlways, ownedByOtherObject, false, 1,
+			"ghTest-uid"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			//given an issue with the object's title on github
+			issue := createFakeGithubIssue()
+			issue.Description = test.description
+			fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+			ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+			ghIssueObj.Spec.AdoptionPolicy = test.policy
+			fakeK8sClient := newFakeK8sClient(ghIssueObj, otherObject())
+
+			r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+			//when reconciling
+			_, err := r.Reconcile(context.Background(), createReq())
+
+			//then the issue is adopted, left alone or a new one is opened according to the policy
+			if (err != nil) != test.expectErr {
+				t.Errorf("Expected error %v but got: %v", test.expectErr, err)
+			}
+			if len(fakeGithubClient.Issues) != test.expectIssues {
+				t.Errorf("Expected %d issues but got: %d", test.expectIssues, len(fakeGithubClient.Issues))
+			}
+			owner, _ := ownerOf(&issue)
+			if owner.UID != test.expectStampedBy {
+				t.Errorf("Expected the issue to be owned by %q but got: %q", test.expectStampedBy, owner.UID)
+			}
+			if test.expectErr {
+				expectCondition(t, getGithubIssueObject(t, fakeK8sClient), examplev1alpha1.ConditionReady,
+					metav1.ConditionFalse, ReasonIssueOwned)
+			}
+		})
+	}
+}
+
+func TestTrackedIssueTakenByAnotherObject(t *testing.T) {
+	//given a tracked issue whose marker was changed to another object's
+	issue := createFakeGithubIssue()
+	issue.Description = ownedByOtherObject
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "open", "", []string{FinalizerName}, false)
+	ghIssueObj.Status.IssueNumber = 1
+	fakeK8sClient := newFakeK8sClient(ghIssueObj, otherObject())
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the issue isn't edited
+	if err == nil {
+		t.Errorf("Expected an error but got nil")
+	}
+	if issue.Description != ownedByOtherObject {
+		t.Errorf("Expected the issue to be left alone but got: %q", issue.Description)
+	}
+}
+
+func TestDeleteLeavesAnotherObjectsIssueOpen(t *testing.T) {
+	//given a deleted object whose title matches an issue of another object
+	issue := createFakeGithubIssue()
+	issue.Description = ownedByOtherObject
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, true)
+	fakeK8sClient := newFakeK8sClient(ghIssueObj, otherObject())
+
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the finalizer is removed and the issue stays open
+	if err != nil {
+		t.Errorf("Expected no error but got an error: %v", err)
+	}
+	if issue.State != "open" {
+		t.Errorf("Expected the issue to stay open but got: %s", issue.State)
+	}
+}
+
+func TestOrphanedIssueAdoptedByAnotherObject(t *testing.T) {
+	//given an issue orphaned by the deleted object in the marker, and an object of another namespace taking it over
+	issue := createFakeGithubIssue()
+	issue.Description = ownedByOtherObject
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	deleted := otherObject()
+	deleted.Spec = examplev1alpha1.GitHubIssueSpec{Repo: "testUser/testRepo", Title: "testIssue",
+		DeletionPolicy: examplev1alpha1.DeletionPolicyOrphan}
+	deleted.Finalizers = []string{FinalizerName}
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+	fakeK8sClient := newFakeK8sClient(ghIssueObj, deleted)
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+	if _, err := r.Reconcile(context.Background(), reconcile.Request{NamespacedName: types.NamespacedName{
+		Namespace: deleted.Namespace, Name: deleted.Name}}); err != nil {
+		t.Fatalf("Expected the orphaning object to let go but got: %v", err)
+	}
+	// the api server removes the object once its finalizer is gone, the fake client doesn't
+	if err := fakeK8sClient.Delete(context.Background(), &deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	//when reconciling the new object under the default adoption policy
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then it adopts the issue
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if owner, _ := ownerOf(&issue); owner.UID != "ghTest-uid" || len(fakeGithubClient.Issues) != 1 {
+		t.Errorf("Expected the issue to be adopted but got owner %q and %d issues", owner.UID, len(fakeGithubClient.Issues))
+	}
+}
+
+func TestIssueOfRecreatedObjectIsUnowned(t *testing.T) {
+	//given an issue marked by an object whose name was reused by a new object since
+	issue := createFakeGithubIssue()
+	issue.Description = ownedByOtherObject
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	recreated := otherObject()
+	recreated.UID = "recreated-uid"
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false)
+	r := createReconciler(fakeGithubClient, newFakeK8sClient(ghIssueObj, recreated), s)
+
+	//when reconciling
+	_, err := r.Reconcile(context.Background(), createReq())
+
+	//then the issue is adopted, the new object never owned it
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if owner, _ := ownerOf(&issue); owner.UID != "ghTest-uid" {
+		t.Errorf("Expected the issue to be adopted but got owner: %q", owner.UID)
+	}
+}
@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	errors2 "github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+//repoChanged: whether spec.repo no longer names the repo the tracked issue is in
+func repoChanged(ghIssue examplev1alpha1.GitHubIssue) bool {
+	return ghIssue.Status.IssueNumber != 0 && ghIssue.Status.Repo != "" &&
+		!strings.EqualFold(ghIssue.Status.Repo, ghIssue.Spec.Repo)
+}
+
+//trackedRepo: the repo the tracked issue is in. spec.repo until the issue number is recorded, and status.repo
+//after that, which only follows spec.repo once the issue was moved there
+func trackedRepo(ghIssue examplev1alpha1.GitHubIssue) string {
+	if ghIssue.Status.IssueNumber != 0 && ghIssue.Status.Repo != "" {
+		return ghIssue.Status.Repo
+	}
+	return ghIssue.Spec.Repo
+}
+
+//trackedSpec: the spec addressed to the repo of the tracked issue, the issue number in the status means nothing
+//in another repo (e.g. while the object is deleted before a change of spec.repo moved the issue)
+func trackedSpec(ghIssue examplev1alpha1.GitHubIssue) examplev1alpha1.GitHubIssueSpec {
+	spec := *ghIssue.Spec.DeepCopy()
+	spec.Repo = trackedRepo(ghIssue)
+	return spec
+}
+
+//sameOwner: whether both "owner/name" repos belong to the same user or organization
+func sameOwner(repo, other string) bool {
+	return strings.EqualFold(strings.SplitN(repo, "/", 2)[0], strings.SplitN(other, "/", 2)[0])
+}
+
+//moveIssue: move the tracked issue from status.repo to spec.repo and record both locations in the status. github
+//transfers issues within an owner, across owners the issue is recreated in the new repo and the old one is
+//closed, each linking to the other. the object is requeued to reconcile the issue where it is now
+func (r *GitHubIssueReconciler) moveIssue(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue,
+	tokenSource github.TokenSource) (ctrl.Result, error) {
+	oldSpec := desiredSpec(ghIssue)
+	oldSpec.Repo = ghIssue.Status.Repo
+	oldNumber := strconv.Itoa(ghIssue.Status.IssueNumber)
+
+	// an earlier attempt may have transferred the issue and failed to record it, the node id follows the issue
+	if ghIssue.Status.NodeID != "" {
+		repo, number, err := r.GithubClient.LocateIssue(ctx, oldSpec, ghIssue.Status.NodeID, tokenSource)
+		if err != nil && !errors2.Is(err, github.ErrNotFound) {
+			return r.handleGithubError(ctx, ghIssue, err, "error during locateIssue")
+		}
+		if err == nil && strings.EqualFold(repo, ghIssue.Spec.Repo) {
+			r.Log.Info("issue already transferred", "githubissue", ghIssue.Name, "repo", repo, "number", number)
+			previous := &examplev1alpha1.IssueLocation{Repo: ghIssue.Status.Repo, IssueNumber: ghIssue.Status.IssueNumber}
+			return r.recordMove(ctx, ghIssue, previous, &github.Issue{IssueNumber: json.Number(number),
+				NodeID: ghIssue.Status.NodeID})
+		}
+	}
+
+	oldIssue, err := r.GithubClient.GetIssue(ctx, oldSpec, oldNumber, tokenSource)
+	if err != nil {
+		return r.handleGithubError(ctx, ghIssue, err, "error during moveIssue")
+	}
+	previous := &examplev1alpha1.IssueLocation{
+		Repo:        ghIssue.Status.Repo,
+		IssueNumber: ghIssue.Status.IssueNumber,
+		URL:         issueURL(oldIssue),
+	}
+
+	var moved *github.Issue
+	if sameOwner(ghIssue.Status.Repo, ghIssue.Spec.Repo) {
+		if moved, err = r.GithubClient.TransferIssue(ctx, oldSpec, oldNumber, ghIssue.Spec.Repo, tokenSource); err != nil {
+			return r.handleGithubError(ctx, ghIssue, err, "error during transferIssue")
+		}
+		r.recordIssueEvent(ghIssue, EventReasonTransferred, moved,
+			fmt.Sprintf("transferred issue %s#%s to", ghIssue.Status.Repo, oldNumber))
+	} else {
+		if moved, err = r.recreateIssue(ctx, ghIssue, oldSpec, oldIssue, tokenSource); err != nil {
+			return r.handleGithubError(ctx, ghIssue, err, "error during recreateIssue")
+		}
+		r.recordIssueEvent(ghIssue, EventReasonRecreated, moved,
+			fmt.Sprintf("closed issue %s#%s of another owner and recreated it as", ghIssue.Status.Repo, oldNumber))
+	}
+
+	return r.recordMove(ctx, ghIssue, previous, moved)
+}
+
+//recordMove: record the issue moved to spec.repo, and where it was before, in the status. the object is requeued
+//to reconcile the issue where it is now
+func (r *GitHubIssueReconciler) recordMove(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue,
+	previous *examplev1alpha1.IssueLocation, moved *github.Issue) (ctrl.Result, error) {
+	patch := client.MergeFrom(ghIssue.DeepCopy())
+	ghIssue.Status.PreviousLocation = previous
+	ghIssue.Status.Repo = ghIssue.Spec.Repo
+	if issueNumber, err := moved.IssueNumber.Int64(); err == nil {
+		ghIssue.Status.IssueNumber = int(issueNumber)
+	}
+	ghIssue.Status.NodeID = moved.NodeID
+	if err := r.Client.Status().Patch(ctx, &ghIssue, patch); err != nil {
+		return ctrl.Result{}, errors2.Wrap(err, "error during moveIssue status update")
+	}
+	return ctrl.Result{Requeue: true}, nil
+}
+
+//recreateIssue: open the issue in the new repo of the spec, unless an earlier attempt already did, and close the
+//old one. the issues link to each other in comments. an issue opened by an earlier attempt is found by the
+//ownership marker of the object, like createIssue does
+func (r *GitHubIssueReconciler) recreateIssue(ctx context.Context, ghIssue examplev1alpha1.GitHubIssue, oldSpec examplev1alpha1.GitHubIssueSpec,
+	oldIssue *github.Issue, tokenSource github.TokenSource) (*github.Issue, error) {
+	newSpec := desiredSpec(ghIssue)
+	created, err := r.GithubClient.FindIssueByMarker(ctx, newSpec, ownerMarkerOf(ghIssue), ghIssue.CreationTimestamp.Time,
+		tokenSource)
+	if err != nil && !errors2.Is(err, github.ErrNotFound) {
+		return nil, err
+	}
+	if created == nil {
+		if created, err = r.GithubClient.Create(ctx, newSpec, tokenSource); err != nil {
+			return nil, err
+		}
+		issuesCreatedTotal.Inc()
+		if _, err = r.GithubClient.CreateComment(ctx, newSpec, string(created.IssueNumber),
+			fmt.Sprintf("Moved from %s.", issueURL(oldIssue)), tokenSource); err != nil {
+			return nil, err
+		}
+	}
+
+	oldNumber := string(oldIssue.IssueNumber)
+	if oldIssue.State != string(examplev1alpha1.IssueStateClosed) {
+		if _, err = r.GithubClient.CreateComment(ctx, oldSpec, oldNumber,
+			fmt.Sprintf("Moved to %s.", issueURL(created)), tokenSource); err != nil {
+			return nil, err
+		}
+		if err = r.GithubClient.Close(ctx, oldSpec, oldNumber, tokenSource); err != nil {
+			return nil, err
+		}
+		issuesClosedTotal.Inc()
+	}
+	return created, nil
+}
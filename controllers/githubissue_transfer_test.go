@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+// newMovedGithubIssue is the test object tracking issue #1 of testUser/testRepo, with spec.repo changed to repo
+func newMovedGithubIssue(repo string) examplev1alpha1.GitHubIssue {
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "open", "", []string{FinalizerName}, false)
+	ghIssueObj.Spec.Repo = repo
+	ghIssueObj.Status.Repo = "testUser/testRepo"
+	ghIssueObj.Status.IssueNumber = 1
+	return ghIssueObj
+}
+
+func TestRepoChanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		repo     string
+		tracked  string
+		number   int
+		expected bool
+	}{
+		{"same repo", "testUser/testRepo", "testUser/testRepo", 1, false},
+		{"repo case changed", "TestUser/testRepo", "testUser/testRepo", 1, false},
+		{"repo changed", "testUser/otherRepo", "testUser/testRepo", 1, true},
+		{"no issue yet", "testUser/otherRepo", "testUser/testRepo", 0, false},
+		{"repo not recorded yet", "testUser/otherRepo", "", 1, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "", "", nil, false)
+			ghIssueObj.Spec.Repo = test.repo
+			ghIssueObj.Status.Repo = test.tracked
+			ghIssueObj.Status.IssueNumber = test.number
+			if changed := repoChanged(ghIssueObj); changed != test.expected {
+				t.Errorf("Expected %v but got: %v", test.expected, changed)
+			}
+		})
+	}
+}
+
+func TestRepoChangeWithinOwnerTransfersIssue(t *testing.T) {
+	//given an issue tracked in testUser/testRepo and an object moved to testUser/otherRepo
+	issue := createFakeGithubIssue()
+	issue.Description = stampedDescription("testing...")
+	issue.HTMLURL = "https://github.com/testUser/testRepo/issues/1"
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	fakeK8sClient := newFakeK8sClient(newMovedGithubIssue("testUser/otherRepo"))
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	result, err := r.Reconcile(context.Background(), createReq())
+
+	//then the issue is transferred, the previous location recorded and the object requeued
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if !result.Requeue {
+		t.Errorf("Expected a requeue but got: %v", result)
+	}
+	if fakeGithubClient.Transfers != 1 || len(fakeGithubClient.Issues) != 1 {
+		t.Errorf("Expected one transferred issue but got %d transfers and %d issues", fakeGithubClient.Transfers,
+			len(fakeGithubClient.Issues))
+	}
+	updated := getGithubIssueObject(t, fakeK8sClient)
+	if updated.Status.Repo != "testUser/otherRepo" || updated.Status.IssueNumber != 2 {
+		t.Errorf("Expected issue #2 of testUser/otherRepo but got: #%d of %s", updated.Status.IssueNumber,
+			updated.Status.Repo)
+	}
+	expected := examplev1alpha1.IssueLocation{Repo: "testUser/testRepo", IssueNumber: 1,
+		URL: "https://github.com/testUser/testRepo/issues/1"}
+	if updated.Status.PreviousLocation == nil || *updated.Status.PreviousLocation != expected {
+		t.Errorf("Expected previous location %v but got: %v", expected, updated.Status.PreviousLocation)
+	}
+
+	//and reconciling again manages the transferred issue without moving it again
+	if result, err = r.Reconcile(context.Background(), createReq()); err != nil || result.Requeue {
+		t.Errorf("Expected a plain reconcile but got: %v, %v", result, err)
+	}
+	if fakeGithubClient.Transfers != 1 {
+		t.Errorf("Expected no other transfer but got: %d", fakeGithubClient.Transfers)
+	}
+}
+
+func TestRepoChangeAcrossOwnersRecreatesIssue(t *testing.T) {
+	//given an issue tracked in testUser/testRepo and an object moved to otherUser/testRepo
+	issue := createFakeGithubIssue()
+	issue.Description = stampedDescription("testing...")
+	issue.HTMLURL = "https://github.com/testUser/testRepo/issues/1"
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	fakeK8sClient := newFakeK8sClient(newMovedGithubIssue("otherUser/testRepo"))
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	result, err := r.Reconcile(context.Background(), createReq())
+
+	//then a new issue is opened in the new repo, the old one is closed and both link to each other
+	if err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+	if result != (ctrl.Result{Requeue: true}) || fakeGithubClient.Transfers != 0 {
+		t.Errorf("Expected a requeue and no transfer but got: %v, %d transfers", result, fakeGithubClient.Transfers)
+	}
+	if len(fakeGithubClient.Issues) != 2 || issue.State != "closed" {
+		t.Fatalf("Expected a new issue and the old one closed but got: %d issues, old one %s",
+			len(fakeGithubClient.Issues), issue.State)
+	}
+	recreated := fakeGithubClient.Issues[1]
+	if !strings.Contains(recreated.Repo, "otherUser/testRepo") {
+		t.Errorf("Expected the new issue in otherUser/testRepo but got: %s", recreated.Repo)
+	}
+	movedTo := fakeGithubClient.Comments["1"]
+	if len(movedTo) != 1 || movedTo[0].Body != "Moved to "+recreated.HTMLURL+"." {
+		t.Errorf("Expected the old issue to link to the new one but got: %v", movedTo)
+	}
+	movedFrom := fakeGithubClient.Comments["2"]
+	if len(movedFrom) != 1 || movedFrom[0].Body != "Moved from "+issue.HTMLURL+"." {
+		t.Errorf("Expected the new issue to link to the old one but got: %v", movedFrom)
+	}
+	updated := getGithubIssueObject(t, fakeK8sClient)
+	if updated.Status.Repo != "otherUser/testRepo" || updated.Status.IssueNumber != 2 ||
+		updated.Status.PreviousLocation == nil || updated.Status.PreviousLocation.IssueNumber != 1 {
+		t.Errorf("Expected issue #2 of otherUser/testRepo moved from #1 but got: %+v", updated.Status)
+	}
+}
+
+func TestRepoChangeRecordsEvent(t *testing.T) {
+	//given an issue tracked in testUser/testRepo and an object moved to testUser/otherRepo
+	issue := createFakeGithubIssue()
+	issue.Description = stampedDescription("testing...")
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+
+	//when reconciling
+	events := reconcileAndGetEvents(fakeGithubClient, newMovedGithubIssue("testUser/otherRepo"))
+
+	//then the transfer is recorded
+	expectEvent(t, events, EventReasonTransferred, "testUser/testRepo#1")
+}
+
+func TestTransferNotRepeatedAfterFailedStatusUpdate(t *testing.T) {
+	//given an issue tracked by node id in testUser/testRepo, an object moved to testUser/otherRepo and a status
+	//update that fails after the transfer
+	issue := createFakeGithubIssue()
+	issue.Description = stampedDescription("testing...")
+	issue.NodeID = "I_1"
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	ghIssueObj := newMovedGithubIssue("testUser/otherRepo")
+	ghIssueObj.Status.NodeID = "I_1"
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+	failing := createReconciler(fakeGithubClient, &failingStatusClient{Client: fakeK8sClient}, s)
+	if _, err := failing.Reconcile(context.Background(), createReq()); err == nil {
+		t.Fatal("Expected the status update to fail")
+	}
+
+	//when reconciling again
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+	result, err := r.Reconcile(context.Background(), createReq())
+
+	//then the issue isn't transferred again, its new location is recorded
+	if err != nil || !result.Requeue {
+		t.Fatalf("Expected a requeue but got: %v, %v", result, err)
+	}
+	if fakeGithubClient.Transfers != 1 {
+		t.Errorf("Expected a single transfer but got: %d", fakeGithubClient.Transfers)
+	}
+	updated := getGithubIssueObject(t, fakeK8sClient)
+	if updated.Status.Repo != "testUser/otherRepo" || updated.Status.IssueNumber != 2 || updated.Status.NodeID != "I_1" {
+		t.Errorf("Expected issue #2 of testUser/otherRepo but got: #%d of %s", updated.Status.IssueNumber,
+			updated.Status.Repo)
+	}
+	if updated.Status.PreviousLocation == nil || updated.Status.PreviousLocation.Repo != "testUser/testRepo" ||
+		updated.Status.PreviousLocation.IssueNumber != 1 {
+		t.Errorf("Expected the previous location #1 of testUser/testRepo but got: %v", updated.Status.PreviousLocation)
+	}
+}
+
+func TestRecreatedIssueFoundByMarker(t *testing.T) {
+	//given an earlier attempt that opened the issue in otherUser/testRepo, renamed there since, before failing
+	issue := createFakeGithubIssue()
+	issue.Description = stampedDescription("testing...")
+	recreated := github.Issue{Repo: "https://api.github.com/repos/otherUser/testRepo/issues", Title: "renamed",
+		Description: stampedDescription("testing..."), IssueNumber: "2", State: "open"}
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue, &recreated}, false, "no error")
+	fakeK8sClient := newFakeK8sClient(newMovedGithubIssue("otherUser/testRepo"))
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	if _, err := r.Reconcile(context.Background(), createReq()); err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+
+	//then the issue is found by the object's marker rather than opened again
+	if len(fakeGithubClient.Issues) != 2 {
+		t.Errorf("Expected no other issue but got: %d issues", len(fakeGithubClient.Issues))
+	}
+	if updated := getGithubIssueObject(t, fakeK8sClient); updated.Status.Repo != "otherUser/testRepo" ||
+		updated.Status.IssueNumber != 2 {
+		t.Errorf("Expected issue #2 of otherUser/testRepo but got: #%d of %s", updated.Status.IssueNumber,
+			updated.Status.Repo)
+	}
+}
+
+func TestDeleteBeforeRepoChangeClosesTrackedIssue(t *testing.T) {
+	//given an object deleted after its spec.repo changed, before the issue was moved
+	issue := createFakeGithubIssue()
+	issue.Description = stampedDescription("testing...")
+	fakeGithubClient := github.NewFakeClient([]*github.Issue{&issue}, false, "no error")
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "open", "", []string{FinalizerName}, true)
+	ghIssueObj.Spec.Repo = "testUser/otherRepo"
+	ghIssueObj.Status.Repo = "testUser/testRepo"
+	ghIssueObj.Status.IssueNumber = 1
+	fakeK8sClient := newFakeK8sClient(ghIssueObj)
+	r := createReconciler(fakeGithubClient, fakeK8sClient, s)
+
+	//when reconciling
+	if _, err := r.Reconcile(context.Background(), createReq()); err != nil {
+		t.Fatalf("Expected no error but got an error: %v", err)
+	}
+
+	//then the issue is closed where it is, the new repo's issue of that number is left alone
+	if issue.State != "closed" {
+		t.Errorf("Expected the tracked issue to be closed but got: %s", issue.State)
+	}
+	for _, repo := range fakeGithubClient.Repos {
+		if repo != "testUser/testRepo" {
+			t.Errorf("Expected calls to testUser/testRepo only but got one to: %s", repo)
+		}
+	}
+}
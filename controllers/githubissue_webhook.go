@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+)
+
+// WebhookSecretEnvVar is the environment variable holding the secret github signs the webhook payloads with
+const WebhookSecretEnvVar = "GITHUB_WEBHOOK_SECRET"
+
+// IssueIndexField indexes GitHubIssue objects by the repo and number of the issue they track
+const IssueIndexField = "status.issueKey"
+
+// maxWebhookPayload is the largest payload github sends (25MB)
+const maxWebhookPayload = 25 << 20
+
+// webhookPayload holds the fields of the issues and issue_comment payloads needed to find the objects
+type webhookPayload struct {
+	Action string `json:"action"`
+	Issue  struct {
+		Number int `json:"number"`
+	} `json:"issue"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+}
+
+// WebhookReceiver receives github's issues and issue_comment webhooks and sends the GitHubIssue objects
+// tracking the issue to the controller, so changes made on github are handled without waiting for the resync
+type WebhookReceiver struct {
+	Client client.Client
+	Log    logr.Logger
+	// Secret the payloads are signed with, as configured on the github webhook
+	Secret []byte
+	// Addr the receiver listens on, e.g. ":9090"
+	Addr string
+	// Events is watched by the controller, see GitHubIssueReconciler.WebhookEvents
+	Events chan<- event.GenericEvent
+}
+
+//issueIndexKey: the key of an issue in the IssueIndexField index
+func issueIndexKey(repo string, issueNumber int) string {
+	return strings.ToLower(repo) + "#" + strconv.Itoa(issueNumber)
+}
+
+//indexIssueKey: index the object by the issue recorded in its status, in the repo the issue is in until a change
+//of spec.repo moved it. objects without an issue aren't indexed
+func indexIssueKey(obj client.Object) []string {
+	ghIssue, ok := obj.(*examplev1alpha1.GitHubIssue)
+	if !ok || ghIssue.Status.IssueNumber == 0 {
+		return nil
+	}
+	return []string{issueIndexKey(trackedRepo(*ghIssue), ghIssue.Status.IssueNumber)}
+}
+
+// Start serves the webhook until the manager stops
+func (w *WebhookReceiver) Start(ctx context.Context) error {
+	server := &http.Server{Addr: w.Addr, Handler: w}
+	errs := make(chan error, 1)
+	go func() {
+		w.Log.Info("starting github webhook receiver", "addr", w.Addr)
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// ServeHTTP verifies a webhook delivery and enqueues the objects tracking its issue
+func (w *WebhookReceiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(rw, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(rw, req.Body, maxWebhookPayload))
+	if err != nil {
+		http.Error(rw, "unable to read the payload", http.StatusBadRequest)
+		return
+	}
+	if !validSignature(w.Secret, body, req.Header.Get("X-Hub-Signature-256")) {
+		http.Error(rw, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	githubEvent := req.Header.Get("X-GitHub-Event")
+	if githubEvent != "issues" && githubEvent != "issue_comment" {
+		// ping and any other event the webhook was subscribed to
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+	payload := webhookPayload{}
+	if err = json.Unmarshal(body, &payload); err != nil || payload.Repository.FullName == "" || payload.Issue.Number == 0 {
+		http.Error(rw, "unable to parse the payload", http.StatusBadRequest)
+		return
+	}
+
+	ghIssues, err := w.findObjectsForIssue(req.Context(), payload.Repository.FullName, payload.Issue.Number)
+	if err != nil {
+		w.Log.Error(err, "unable to list githubissues for webhook", "repo", payload.Repository.FullName,
+			"issue number", payload.Issue.Number)
+		http.Error(rw, "unable to list githubissues", http.StatusInternalServerError)
+		return
+	}
+	for i := range ghIssues {
+		select {
+		case w.Events <- event.GenericEvent{Object: &ghIssues[i]}:
+		case <-req.Context().Done():
+			http.Error(rw, "timed out enqueueing githubissues", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Log.Info("received github webhook", "event", githubEvent, "action", payload.Action,
+		"repo", payload.Repository.FullName, "issue number", payload.Issue.Number, "objects", len(ghIssues))
+	rw.WriteHeader(http.StatusAccepted)
+}
+
+//findObjectsForIssue: the GitHubIssue objects, in all namespaces, tracking the issue
+func (w *WebhookReceiver) findObjectsForIssue(ctx context.Context, repo string, issueNumber int) ([]examplev1alpha1.GitHubIssue, error) {
+	key := issueIndexKey(repo, issueNumber)
+	ghIssues := examplev1alpha1.GitHubIssueList{}
+	if err := w.Client.List(ctx, &ghIssues, client.MatchingFields{IssueIndexField: key}); err != nil {
+		return nil, fmt.Errorf("listing githubissues of %s: %w", key, err)
+	}
+	// clients without the index (like the fake client) ignore the field selector, so check the key again
+	var matching []examplev1alpha1.GitHubIssue
+	for _, ghIssue := range ghIssues.Items {
+		if keys := indexIssueKey(&ghIssue); len(keys) == 1 && keys[0] == key {
+			matching = append(matching, ghIssue)
+		}
+	}
+	return matching, nil
+}
+
+//validSignature: check the X-Hub-Signature-256 header is the HMAC of the body with the secret
+func validSignature(secret, body []byte, signature string) bool {
+	if len(secret) == 0 || !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+func sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(testWebhookSecret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func newTestWebhookReceiver(t *testing.T) (*WebhookReceiver, chan event.GenericEvent) {
+	tracked := newGithubIssueRuntimeObject("testIssue", "testing...", "open", "", []string{FinalizerName}, false)
+	tracked.Status.IssueNumber = 1
+	other := *tracked.DeepCopy()
+	other.Name = "ghOther"
+	other.Status.IssueNumber = 2
+	events := make(chan event.GenericEvent, 10)
+	return &WebhookReceiver{
+		Client: newFakeK8sClient(tracked, other),
+		Log:    ctrl.Log.WithName("webhooks").WithName("GitHub"),
+		Secret: []byte(testWebhookSecret),
+		Events: events,
+	}, events
+}
+
+func deliver(receiver *WebhookReceiver, githubEvent string, body []byte, signature string) int {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	req.Header.Set("X-GitHub-Event", githubEvent)
+	req.Header.Set("X-Hub-Signature-256", signature)
+	rw := httptest.NewRecorder()
+	receiver.ServeHTTP(rw, req)
+	return rw.Code
+}
+
+func TestWebhookEnqueuesTrackingObject(t *testing.T) {
+	//given a receiver and objects tracking issues 1 and 2 (repo names are case insensitive on github)
+	receiver, events := newTestWebhookReceiver(t)
+	body := []byte(`{"action":"edited","issue":{"number":1},"repository":{"full_name":"TestUser/testRepo"}}`)
+
+	//when github delivers an edit of issue 1
+	code := deliver(receiver, "issues", body, sign(body))
+
+	//then only the object tracking issue 1 is sent to the controller
+	if code != http.StatusAccepted {
+		t.Errorf("Expected status %d but got: %d", http.StatusAccepted, code)
+	}
+	if len(events) != 1 {
+		t.Fatalf("Expected one event but got: %d", len(events))
+	}
+	if name := (<-events).Object.GetName(); name != "ghTest" {
+		t.Errorf("Expected the object tracking the issue but got: %s", name)
+	}
+}
+
+func TestWebhookRejectsInvalidSignature(t *testing.T) {
+	//given a receiver
+	receiver, events := newTestWebhookReceiver(t)
+	body := []byte(`{"action":"edited","issue":{"number":1},"repository":{"full_name":"TestUser/testRepo"}}`)
+
+	//when a payload is delivered with a wrong or missing signature
+	wrongCode := deliver(receiver, "issue_comment", body, sign([]byte("something else")))
+	missingCode := deliver(receiver, "issue_comment", body, "")
+
+	//then it's rejected and nothing is enqueued
+	if wrongCode != http.StatusUnauthorized || missingCode != http.StatusUnauthorized {
+		t.Errorf("Expected unauthorized but got: %d, %d", wrongCode, missingCode)
+	}
+	if len(events) != 0 {
+		t.Errorf("Expected no events but got: %d", len(events))
+	}
+}
+
+func TestWebhookIgnoresOtherEvents(t *testing.T) {
+	//given a receiver
+	receiver, events := newTestWebhookReceiver(t)
+	body := []byte(`{"zen":"Keep it logically awesome."}`)
+
+	//when github pings the webhook
+	code := deliver(receiver, "ping", body, sign(body))
+
+	//then the ping is acknowledged and nothing is enqueued
+	if code != http.StatusNoContent || len(events) != 0 {
+		t.Errorf("Expected an acknowledged ping but got: %d, %d events", code, len(events))
+	}
+}
+
+func TestIssueIndexedInTrackedRepo(t *testing.T) {
+	//given an object whose spec.repo changed, before its issue was moved
+	ghIssueObj := newMovedGithubIssue("testUser/otherRepo")
+
+	//when indexing it
+	keys := indexIssueKey(&ghIssueObj)
+
+	//then it's indexed by the repo the issue is still in
+	if len(keys) != 1 || keys[0] != issueIndexKey("testUser/testRepo", 1) {
+		t.Errorf("Expected the issue of testUser/testRepo but got: %v", keys)
+	}
+}
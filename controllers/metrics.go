@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+)
+
+// issueStatePending is the state label of objects whose issue wasn't created yet
+const issueStatePending = "pending"
+
+var (
+	issuesCreatedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "githubissue_issues_created_total",
+		Help: "Number of issues the operator created on GitHub.",
+	})
+	issuesEditedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "githubissue_issues_edited_total",
+		Help: "Number of issues the operator edited on GitHub.",
+	})
+	issuesClosedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "githubissue_issues_closed_total",
+		Help: "Number of issues the operator closed on GitHub.",
+	})
+	managedIssuesDesc = prometheus.NewDesc("githubissue_managed_issues",
+		"Number of GitHubIssue objects by the state of their issue on GitHub.", []string{"state"}, nil)
+)
+
+func init() {
+	metrics.Registry.MustRegister(issuesCreatedTotal, issuesEditedTotal, issuesClosedTotal)
+}
+
+// managedIssuesCollector counts the GitHubIssue objects by state when the metrics are scraped, so deleted
+// objects drop out without the reconciler keeping track of them
+type managedIssuesCollector struct {
+	client client.Client
+	log    logr.Logger
+}
+
+func (c *managedIssuesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- managedIssuesDesc
+}
+
+func (c *managedIssuesCollector) Collect(ch chan<- prometheus.Metric) {
+	ghIssues := examplev1alpha1.GitHubIssueList{}
+	if err := c.client.List(context.Background(), &ghIssues); err != nil {
+		c.log.Error(err, "unable to list githubissues for metrics")
+		return
+	}
+	counts := map[string]int{
+		string(examplev1alpha1.IssueStateOpen):   0,
+		string(examplev1alpha1.IssueStateClosed): 0,
+		issueStatePending:                        0,
+	}
+	for _, ghIssue := range ghIssues.Items {
+		state := ghIssue.Status.State
+		if state == "" {
+			state = issueStatePending
+		}
+		counts[state]++
+	}
+	for state, count := range counts {
+		ch <- prometheus.MustNewConstMetric(managedIssuesDesc, prometheus.GaugeValue, float64(count), state)
+	}
+}
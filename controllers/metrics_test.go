@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	examplev1alpha1 "github.com/ShellyKatz/example-operator/api/v1alpha1"
+	"github.com/ShellyKatz/example-operator/controllers/github"
+)
+
+func TestManagedIssuesCollector(t *testing.T) {
+	//given an open, a closed and a not yet created issue
+	open := newGithubIssueRuntimeObject("openIssue", "testing...", "open", "", []string{}, false)
+	closed := *open.DeepCopy()
+	closed.Name = "closed"
+	closed.Status.State = "closed"
+	pending := *open.DeepCopy()
+	pending.Name = "pending"
+	pending.Status.State = ""
+	collector := &managedIssuesCollector{
+		client: newFakeK8sClient(open, closed, pending),
+		log:    ctrl.Log.WithName("metrics"),
+	}
+
+	//when collecting
+	expected := `
+# HELP githubissue_managed_issues Number of GitHubIssue objects by the state of their issue on GitHub.
+# TYPE githubissue_managed_issues gauge
+githubissue_managed_issues{state="closed"} 1
+githubissue_managed_issues{state="open"} 1
+githubissue_managed_issues{state="pending"} 1
+`
+	err := testutil.CollectAndCompare(collector, strings.NewReader(expected))
+
+	//then every state is counted
+	if err != nil {
+		t.Errorf("Expected the issues to be counted by state but got: %v", err)
+	}
+}
+
+func TestSideEffectsAreCounted(t *testing.T) {
+	created, edited, closed := testutil.ToFloat64(issuesCreatedTotal), testutil.ToFloat64(issuesEditedTotal),
+		testutil.ToFloat64(issuesClosedTotal)
+
+	//given an empty repository, and an open issue whose object wants it closed
+	//when reconciling both
+	_ = reconcileAndGetEvents(github.NewFakeClient([]*github.Issue{}, false, "no error"),
+		newGithubIssueRuntimeObject("testIssue", "testing...", "", "", []string{FinalizerName}, false))
+	issue := createFakeGithubIssue()
+	ghIssueObj := newGithubIssueRuntimeObject("testIssue", "testing...", "open", "", []string{FinalizerName}, false)
+	ghIssueObj.Spec.State = examplev1alpha1.IssueStateClosed
+	_ = reconcileAndGetEvents(github.NewFakeClient([]*github.Issue{&issue}, false, "no error"), ghIssueObj)
+
+	//then one issue is counted as created, and one as edited and closed
+	if got := testutil.ToFloat64(issuesCreatedTotal) - created; got != 1 {
+		t.Errorf("Expected one created issue to be counted but got: %v", got)
+	}
+	if got := testutil.ToFloat64(issuesEditedTotal) - edited; got != 1 {
+		t.Errorf("Expected one edited issue to be counted but got: %v", got)
+	}
+	if got := testutil.ToFloat64(issuesClosedTotal) - closed; got != 1 {
+		t.Errorf("Expected one closed issue to be counted but got: %v", got)
+	}
+}
@@ -18,7 +18,9 @@ package main
 
 import (
 	"flag"
+	"net/http"
 	"os"
+	"time"
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -27,6 +29,7 @@ import (
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
@@ -52,11 +55,53 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var rateLimitReserve int
+	var githubAPIURL string
+	var resyncInterval time.Duration
+	var webhookAddr string
+	var enableWebhooks bool
+	var cacheSize int
+	var requestTimeout time.Duration
+	var proxyURL string
+	var caFile string
+	retryPolicy := github.DefaultRetryPolicy()
+	var githubAPI string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.IntVar(&rateLimitReserve, "github-rate-limit-reserve", 10,
+		"Number of GitHub API requests left unused in every rate limit window before the operator stops calling GitHub.")
+	flag.IntVar(&cacheSize, "github-cache-size", github.DefaultCacheSize,
+		"Number of GitHub API responses kept to send requests conditionally with their ETag, 0 turns the cache off.")
+	flag.DurationVar(&requestTimeout, "github-request-timeout", github.DefaultRequestTimeout,
+		"How long a single GitHub API request may take before it's cancelled.")
+	flag.StringVar(&proxyURL, "github-proxy-url", "",
+		"Proxy every GitHub API request goes through. Empty uses the HTTPS_PROXY and NO_PROXY environment variables.")
+	flag.StringVar(&caFile, "github-ca-file", "",
+		"PEM bundle of certificate authorities trusted for the GitHub API on top of the system ones, "+
+			"e.g. the CA of a GitHub Enterprise Server.")
+	flag.DurationVar(&retryPolicy.MaxElapsedTime, "github-retry-max-elapsed-time", github.DefaultRetryMaxElapsedTime,
+		"How long a GitHub API request failing with a 5xx or a network error keeps being retried, 0 turns retries off. "+
+			"Only requests that are safe to send again are retried.")
+	flag.DurationVar(&retryPolicy.InitialInterval, "github-retry-initial-interval", github.DefaultRetryInitialInterval,
+		"How long the first retry of a failed GitHub API request waits, doubled for every following retry.")
+	flag.DurationVar(&retryPolicy.MaxInterval, "github-retry-max-interval", github.DefaultRetryMaxInterval,
+		"The longest wait between two retries of a failed GitHub API request.")
+	flag.StringVar(&githubAPI, "github-api", "rest",
+		"The GitHub API the operator uses, rest or graphql. graphql fetches an issue with its labels, assignees, "+
+			"comments and project items in one request, project items are left out when the token lacks the read:project scope.")
+	flag.StringVar(&githubAPIURL, "github-api-url", github.APIBaseURL,
+		"Base URL of the GitHub API, e.g. https://github.example.com/api/v3/ for GitHub Enterprise Server.")
+	flag.DurationVar(&resyncInterval, "resync-interval", controllers.DefaultResyncInterval,
+		"How often every issue is compared against GitHub to revert changes made there, 0 to turn it off. "+
+			"Objects may override it with spec.resyncInterval.")
+	flag.StringVar(&webhookAddr, "github-webhook-bind-address", "",
+		"The address the GitHub webhook receiver binds to, e.g. :9090. Empty turns the receiver off. "+
+			"Payloads are verified with the secret in the "+controllers.WebhookSecretEnvVar+" environment variable.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the defaulting and validating admission webhooks of GitHubIssue on port 9443.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -65,8 +110,6 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
-	//minute := time.Minute
-
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -74,22 +117,85 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "c5f6822b.training.redhat.com",
-		//	SyncPeriod:             &minute,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	var webhookEvents chan event.GenericEvent
+	if webhookAddr != "" {
+		secret := os.Getenv(controllers.WebhookSecretEnvVar)
+		if secret == "" {
+			setupLog.Error(nil, "the github webhook receiver needs a secret", "env", controllers.WebhookSecretEnvVar)
+			os.Exit(1)
+		}
+		webhookEvents = make(chan event.GenericEvent)
+		if err = mgr.Add(&controllers.WebhookReceiver{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("webhooks").WithName("GitHub"),
+			Secret: []byte(secret),
+			Addr:   webhookAddr,
+			Events: webhookEvents,
+		}); err != nil {
+			setupLog.Error(err, "unable to add the github webhook receiver")
+			os.Exit(1)
+		}
+	}
+
+	transport, err := github.NewTransport(github.TransportOptions{ProxyURL: proxyURL, CAFile: caFile})
+	if err != nil {
+		setupLog.Error(err, "unable to set up the github transport")
+		os.Exit(1)
+	}
+	httpClient := &http.Client{Transport: github.NewRetryTransport(transport, retryPolicy)}
+
+	var responseCache *github.ResponseCache
+	if cacheSize > 0 {
+		responseCache = github.NewResponseCache(cacheSize)
+	}
+	apiClient := &github.ClientAPI{
+		BaseURL:    githubAPIURL,
+		RateLimits: github.NewRateLimitTracker(rateLimitReserve),
+		Cache:      responseCache,
+		HTTPClient: httpClient,
+		Timeout:    requestTimeout,
+	}
+	appTokens := &github.AppTokenSources{
+		BaseURL:    githubAPIURL,
+		HTTPClient: httpClient,
+		Timeout:    requestTimeout,
+	}
+	var githubClient github.Client
+	switch githubAPI {
+	case "rest":
+		githubClient = apiClient
+	case "graphql":
+		githubClient = &github.GraphQLClient{API: apiClient}
+	default:
+		setupLog.Error(nil, "unknown github api, use rest or graphql", "github-api", githubAPI)
+		os.Exit(1)
+	}
+
 	if err = (&controllers.GitHubIssueReconciler{
-		Client:       mgr.GetClient(),
-		Log:          ctrl.Log.WithName("controllers").WithName("GitHubIssue"),
-		Scheme:       mgr.GetScheme(),
-		GithubClient: &github.ClientAPI{},
+		Client:         mgr.GetClient(),
+		Log:            ctrl.Log.WithName("controllers").WithName("GitHubIssue"),
+		Scheme:         mgr.GetScheme(),
+		GithubClient:   githubClient,
+		AppTokens:      appTokens,
+		Recorder:       mgr.GetEventRecorderFor("githubissue-controller"),
+		ResyncInterval: resyncInterval,
+		WebhookEvents:  webhookEvents,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "GitHubIssue")
 		os.Exit(1)
 	}
+	if enableWebhooks {
+		if err = (&examplev1alpha1.GitHubIssue{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "GitHubIssue")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {